@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// BoundRow is one line of the bounds file: the bounds on the optimal TNC of
+// a configuration found by one method. Proven rows hold the optimum.
+type BoundRow struct {
+	Dataset    string
+	Hubs       int
+	Alpha      float64
+	Method     string
+	LowerBound float64
+	UpperBound float64
+	Proven     bool
+	Locations  []int
+}
+
+var boundsHeader = []string{"dataset", "hubs", "alpha", "method", "lower_bound", "upper_bound", "proven", "hub_locations"}
+
+func (r BoundRow) key() string {
+	return fmt.Sprintf("%s|%d|%f|%s", r.Dataset, r.Hubs, r.Alpha, r.Method)
+}
+
+// a missing file holds no bounds
+func readBounds(location string) (rows []BoundRow, err error) {
+	f, err := os.Open(location)
+	if os.IsNotExist(err) {
+		return rows, nil
+	}
+	if err != nil {
+		return rows, err
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return rows, err
+	}
+	for n, record := range records {
+		if n == 0 || len(record) < len(boundsHeader) {
+			continue
+		}
+		row := BoundRow{Dataset: record[0], Method: record[3]}
+		if row.Hubs, err = strconv.Atoi(record[1]); err != nil {
+			return rows, err
+		}
+		if row.Alpha, err = strconv.ParseFloat(record[2], 64); err != nil {
+			return rows, err
+		}
+		if row.LowerBound, err = strconv.ParseFloat(record[4], 64); err != nil {
+			return rows, err
+		}
+		if row.UpperBound, err = strconv.ParseFloat(record[5], 64); err != nil {
+			return rows, err
+		}
+		if row.Proven, err = strconv.ParseBool(record[6]); err != nil {
+			return rows, err
+		}
+		for _, field := range strings.Fields(record[7]) {
+			hub, err := strconv.Atoi(field)
+			if err != nil {
+				return rows, err
+			}
+			row.Locations = append(row.Locations, hub)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// replace the row of the same configuration and method, or append it
+func mergeBound(rows []BoundRow, row BoundRow) []BoundRow {
+	for i, r := range rows {
+		if r.key() == row.key() {
+			rows[i] = row
+			return rows
+		}
+	}
+	return append(rows, row)
+}
+
+func writeBounds(location string, rows []BoundRow) error {
+	f, err := os.Create(location)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write(boundsHeader)
+	for _, r := range rows {
+		locations := make([]string, len(r.Locations))
+		for i, hub := range r.Locations {
+			locations[i] = strconv.Itoa(hub)
+		}
+		w.Write([]string{
+			r.Dataset,
+			strconv.Itoa(r.Hubs),
+			fmt.Sprintf("%f", r.Alpha),
+			r.Method,
+			fmt.Sprintf("%.10g", r.LowerBound),
+			fmt.Sprintf("%.10g", r.UpperBound),
+			strconv.FormatBool(r.Proven),
+			strings.Join(locations, " "),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
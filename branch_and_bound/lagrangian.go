@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Lagrangian relaxation configuration
+var lagrangianIterations = 500
+var lagrangianStall = 20
+
+// LagrangianRelaxation bounds the single allocation p-hub median from below.
+//
+// Every O-D pair i, j with flow is routed on a path i -> k -> m -> j with
+// x_ijkm = 1, hubs are the y_k = 1 with sum y = p, and a path may only use
+// open hubs:
+//
+//	sum_m x_ijkm <= y_k    (u_ijk >= 0)
+//	sum_k x_ijkm <= y_m    (v_ijm >= 0)
+//
+// This is the multiple allocation problem, a relaxation of single
+// allocation. Relaxing the linking constraints leaves, for every pair, the
+// cheapest path under the penalised costs and, for the hubs, the p largest
+// sums of multipliers. The multipliers are tuned by subgradient
+// optimisation, and the hubs of every subproblem are turned into a single
+// allocation solution, the Lagrangian heuristic upper bound. Under hub
+// constraints the paths only use the hubs their end points may be
+// allocated to and the forced hubs are always open.
+func LagrangianRelaxation(number_of_hubs int) Result {
+	start := time.Now()
+	n := len(cost_matrix)
+
+	type pair struct{ i, j int }
+	pairs := []pair{}
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if flow_matrix[i][j] > 0 {
+				pairs = append(pairs, pair{i, j})
+			}
+		}
+	}
+
+	u := make([][]float64, len(pairs))
+	v := make([][]float64, len(pairs))
+	for q := range pairs {
+		u[q] = make([]float64, n)
+		v[q] = make([]float64, n)
+	}
+	first := make([]int, len(pairs))
+	second := make([]int, len(pairs))
+
+	// the greedy solution of the branch and bound is the first upper bound
+	b := &BranchAndBound{n: n, p: number_of_hubs}
+	b.initialSolution()
+	best := b.best
+	evaluated := map[string]bool{fmt.Sprint(sortedCopy(best.Hubs)): true}
+
+	lower_bound := math.Inf(-1)
+	theta := 2.0
+	stall := 0
+	iteration := 0
+	for ; iteration < lagrangianIterations; iteration++ {
+		if timeLimit > 0 && time.Since(start) > timeLimit {
+			break
+		}
+
+		// cheapest penalised path of every pair
+		value := 0.0
+		for q, p := range pairs {
+			w := flow_matrix[p.i][p.j]
+			best_cost := math.Inf(1)
+			for m := 0; m < n; m++ {
+				if !canBeHub(m) || !canAllocate(p.j, m) {
+					continue
+				}
+				leg, leg_k := math.Inf(1), -1
+				for k := 0; k < n; k++ {
+					if !canBeHub(k) || !canAllocate(p.i, k) {
+						continue
+					}
+					c := w*(cost_matrix[p.i][k]+alpha*cost_matrix[k][m]) + u[q][k]
+					if c < leg {
+						leg, leg_k = c, k
+					}
+				}
+				c := leg + w*cost_matrix[m][p.j] + v[q][m]
+				if c < best_cost {
+					best_cost = c
+					first[q], second[q] = leg_k, m
+				}
+			}
+			value += best_cost
+		}
+
+		// hubs with the largest multiplier sums
+		weight := make([]float64, n)
+		for q := range pairs {
+			for k := 0; k < n; k++ {
+				weight[k] += u[q][k] + v[q][k]
+			}
+		}
+		nodes := []int{}
+		for k := 0; k < n; k++ {
+			if canBeHub(k) && !isForced(k) {
+				nodes = append(nodes, k)
+			}
+		}
+		sort.SliceStable(nodes, func(x, y int) bool { return weight[nodes[x]] > weight[nodes[y]] })
+		hubs := append(append([]int{}, forcedHubs...), nodes[:number_of_hubs-len(forcedHubs)]...)
+		open := make([]float64, n)
+		for _, k := range hubs {
+			open[k] = 1
+			value -= weight[k]
+		}
+
+		if iteration == 0 || value > lower_bound+1e-9*math.Abs(lower_bound) {
+			lower_bound = value
+			stall = 0
+		} else {
+			stall++
+			if stall >= lagrangianStall {
+				theta /= 2
+				stall = 0
+			}
+		}
+
+		// Lagrangian heuristic, evaluate every new hub set once
+		key := fmt.Sprint(sortedCopy(hubs))
+		if !evaluated[key] {
+			evaluated[key] = true
+			heuristic_hubs := sortedCopy(hubs)
+			solution, cost := improvedAllocation(heuristic_hubs)
+			if cost < best.Cost {
+				best = Result{Hubs: heuristic_hubs, Solution: solution, Cost: cost}
+			}
+		}
+
+		if best.Cost-lower_bound <= 1e-9*best.Cost || theta < 1e-4 {
+			break
+		}
+
+		// subgradient of the relaxed constraints at the subproblem optimum
+		norm := 0.0
+		for q := range pairs {
+			for k := 0; k < n; k++ {
+				g_u, g_v := -open[k], -open[k]
+				if first[q] == k {
+					g_u++
+				}
+				if second[q] == k {
+					g_v++
+				}
+				if g_u > 0 || u[q][k] > 0 {
+					norm += g_u * g_u
+				}
+				if g_v > 0 || v[q][k] > 0 {
+					norm += g_v * g_v
+				}
+			}
+		}
+		if norm == 0 {
+			break
+		}
+		step := theta * (best.Cost - value) / norm
+		for q := range pairs {
+			for k := 0; k < n; k++ {
+				g_u, g_v := -open[k], -open[k]
+				if first[q] == k {
+					g_u++
+				}
+				if second[q] == k {
+					g_v++
+				}
+				u[q][k] = math.Max(0, u[q][k]+step*g_u)
+				v[q][k] = math.Max(0, v[q][k]+step*g_v)
+			}
+		}
+	}
+
+	best.LowerBound = math.Min(lower_bound, best.Cost)
+	best.Proven = best.Cost-lower_bound <= 1e-9*best.Cost
+	best.Nodes = iteration
+	best.Elapsed = time.Since(start)
+	return best
+}
+
+func sortedCopy(list []int) []int {
+	sorted := append([]int{}, list...)
+	sort.Ints(sorted)
+	return sorted
+}
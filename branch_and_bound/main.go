@@ -0,0 +1,660 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var cost_matrix = [][]float64{}
+var flow_matrix = [][]float64{}
+var total_flow float64
+
+var no_hubs = 3
+var alpha = 0.8
+
+// Configurations
+var maxNodes = 25
+var nodeLimit = 0
+var timeLimit time.Duration
+var boundsFile = "bounds.csv"
+var method = "bnb"
+
+func read_matrix(location string, no_nodes int) (matrix [][]float64, err error) {
+	f, err := os.Open(location)
+	if err != nil {
+		return matrix, err
+	}
+	defer f.Close()
+
+	// Create a new reader.
+	r := csv.NewReader(bufio.NewReader(f))
+
+	row := []float64{}
+
+	for {
+		record, err := r.Read()
+
+		// Stop at EOF.
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return matrix, err
+		}
+		if len(matrix) == no_nodes || len(record) != no_nodes {
+			return matrix, fmt.Errorf("%s is not a %d x %d matrix, row %d has %d columns", location, no_nodes, no_nodes, len(matrix)+1, len(record))
+		}
+
+		for i := 0; i < no_nodes; i++ {
+			value, err := strconv.ParseFloat(record[i], 10)
+			if err != nil {
+				return matrix, err
+			} else {
+				row = append(row, value)
+			}
+		}
+		matrix = append(matrix, row)
+		row = []float64{}
+	}
+	if len(matrix) != no_nodes {
+		return matrix, fmt.Errorf("%s is not a %d x %d matrix, it has %d rows", location, no_nodes, no_nodes, len(matrix))
+	}
+
+	return matrix, nil
+}
+
+func calcTotalFlow(flow_matrix [][]float64) float64 {
+	total_flow := 0.0
+	for _, c := range flow_matrix {
+		for _, e := range c {
+			total_flow += e
+		}
+	}
+	return total_flow
+}
+
+// calculate total_cost follow Spoke-Hub-Hub-spoke strategy
+func calcTotalCost(cost_matrix, flow_matrix [][]float64, alpha float64, solution []int) float64 {
+	var total_cost float64
+	total_cost = 0.0
+	for i, _ := range flow_matrix {
+		for j, _ := range flow_matrix {
+			collection_cost := flow_matrix[i][j] * cost_matrix[i][solution[i]]
+			transportation_cost := flow_matrix[i][j] * cost_matrix[solution[i]][solution[j]] * alpha
+			distribution_cost := flow_matrix[i][j] * cost_matrix[solution[j]][j]
+			cost := collection_cost + transportation_cost + distribution_cost
+			total_cost += cost
+		}
+	}
+	return total_cost
+}
+
+func isInSlice(a int, list []int) bool {
+	for _, b := range list {
+		if b == a {
+			return true
+		}
+	}
+	return false
+}
+
+// cost of routing every O-D pair through the cheapest pair of hubs in
+// hubs the end points may be allocated to. Any single allocation over a
+// subset of hubs costs at least as much, which makes it a lower bound for
+// every node of the hub tree.
+func calcMultipleAllocationCost(hubs []int) float64 {
+	n := len(cost_matrix)
+	// to_hub[i][m] is the cheapest way from i to hub m through a first hub
+	to_hub := make([][]float64, n)
+	for i := 0; i < n; i++ {
+		to_hub[i] = make([]float64, n)
+		for _, m := range hubs {
+			best := math.Inf(1)
+			for _, k := range allowedHubs(i, hubs) {
+				c := cost_matrix[i][k] + alpha*cost_matrix[k][m]
+				if c < best {
+					best = c
+				}
+			}
+			to_hub[i][m] = best
+		}
+	}
+
+	total_cost := 0.0
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if flow_matrix[i][j] == 0 {
+				continue
+			}
+			best := math.Inf(1)
+			for _, m := range allowedHubs(j, hubs) {
+				c := to_hub[i][m] + cost_matrix[m][j]
+				if c < best {
+					best = c
+				}
+			}
+			total_cost += flow_matrix[i][j] * best
+		}
+	}
+	return total_cost
+}
+
+// Result of the branch and bound of one configuration
+type Result struct {
+	Hubs       []int
+	Solution   []int
+	Cost       float64
+	LowerBound float64
+	Nodes      int // branch and bound nodes, or subgradient iterations
+	Proven     bool
+	Elapsed    time.Duration
+}
+
+type BranchAndBound struct {
+	n     int
+	p     int
+	order []int
+
+	best      Result
+	openBound float64 // smallest bound of the nodes left open by a limit
+	nodes     int
+	start     time.Time
+	stopped   bool
+
+	// allocation subproblem of the current hub set
+	hubs   []int
+	spokes []int
+	assign []int
+	bound  float64
+	// to_hub[i][m]: cheapest way from unassigned i to hub m
+	// from_hub[k][j]: cheapest way from hub k to unassigned j
+	// free[i][j]: cheapest path between two unassigned nodes
+	to_hub   [][]float64
+	from_hub [][]float64
+	free     [][]float64
+}
+
+func (b *BranchAndBound) limitReached() bool {
+	if b.stopped {
+		return true
+	}
+	if nodeLimit > 0 && b.nodes >= nodeLimit {
+		b.stopped = true
+	}
+	if timeLimit > 0 && b.nodes%1000 == 0 && time.Since(b.start) > timeLimit {
+		b.stopped = true
+	}
+	return b.stopped
+}
+
+func (b *BranchAndBound) leaveOpen(bound float64) {
+	if bound < b.openBound {
+		b.openBound = bound
+	}
+}
+
+func pruned(bound, upper float64) bool {
+	return bound >= upper-1e-9*math.Abs(upper)
+}
+
+// lower bound on the pair i -> j under the current partial allocation
+func (b *BranchAndBound) pairBound(i, j int) float64 {
+	w := flow_matrix[i][j]
+	if w == 0 {
+		return 0
+	}
+	ai, aj := b.assign[i], b.assign[j]
+	switch {
+	case ai >= 0 && aj >= 0:
+		return w * (cost_matrix[i][ai] + alpha*cost_matrix[ai][aj] + cost_matrix[aj][j])
+	case ai >= 0:
+		return w * (cost_matrix[i][ai] + b.from_hub[ai][j])
+	case aj >= 0:
+		return w * (b.to_hub[i][aj] + cost_matrix[aj][j])
+	}
+	return w * b.free[i][j]
+}
+
+// bound contributed by the pairs starting or ending at node
+func (b *BranchAndBound) nodeBound(node int) float64 {
+	bound := 0.0
+	for j := 0; j < b.n; j++ {
+		bound += b.pairBound(node, j)
+		if j != node {
+			bound += b.pairBound(j, node)
+		}
+	}
+	return bound
+}
+
+func (b *BranchAndBound) prepareAllocation(hubs []int) {
+	n := b.n
+	b.hubs = hubs
+	b.to_hub = make([][]float64, n)
+	b.from_hub = make([][]float64, n)
+	b.free = make([][]float64, n)
+	for i := 0; i < n; i++ {
+		b.to_hub[i] = make([]float64, n)
+		b.from_hub[i] = make([]float64, n)
+		b.free[i] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		for _, m := range hubs {
+			to, from := math.Inf(1), math.Inf(1)
+			for _, k := range allowedHubs(i, hubs) {
+				if c := cost_matrix[i][k] + alpha*cost_matrix[k][m]; c < to {
+					to = c
+				}
+				if c := alpha*cost_matrix[m][k] + cost_matrix[k][i]; c < from {
+					from = c
+				}
+			}
+			b.to_hub[i][m] = to
+			b.from_hub[m][i] = from
+		}
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			best := math.Inf(1)
+			for _, m := range allowedHubs(j, hubs) {
+				if c := b.to_hub[i][m] + cost_matrix[m][j]; c < best {
+					best = c
+				}
+			}
+			b.free[i][j] = best
+		}
+	}
+
+	// hubs are allocated to themselves, the spokes with the most flow are
+	// branched on first
+	b.assign = make([]int, n)
+	b.spokes = b.spokes[:0]
+	for i := 0; i < n; i++ {
+		b.assign[i] = -1
+		if isInSlice(i, hubs) {
+			b.assign[i] = i
+		} else {
+			b.spokes = append(b.spokes, i)
+		}
+	}
+	sort.SliceStable(b.spokes, func(x, y int) bool {
+		return nodeFlow(b.spokes[x]) > nodeFlow(b.spokes[y])
+	})
+
+	b.bound = 0
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			b.bound += b.pairBound(i, j)
+		}
+	}
+}
+
+func nodeFlow(i int) float64 {
+	f := 0.0
+	for j, _ := range flow_matrix {
+		f += flow_matrix[i][j] + flow_matrix[j][i]
+	}
+	return f
+}
+
+func (b *BranchAndBound) branchAllocation(t int) {
+	if t == len(b.spokes) {
+		if b.bound < b.best.Cost {
+			b.best.Cost = calcTotalCost(cost_matrix, flow_matrix, alpha, b.assign)
+			b.best.Hubs = append([]int{}, b.hubs...)
+			b.best.Solution = append([]int{}, b.assign...)
+		}
+		return
+	}
+
+	b.nodes++
+	if b.limitReached() {
+		b.leaveOpen(b.bound)
+		return
+	}
+
+	spoke := b.spokes[t]
+	before := b.nodeBound(spoke)
+	children := make([]float64, len(b.hubs))
+	for c, hub := range b.hubs {
+		// a forbidden allocation is a child that is always pruned
+		if !canAllocate(spoke, hub) {
+			children[c] = math.Inf(1)
+			continue
+		}
+		b.assign[spoke] = hub
+		children[c] = b.bound - before + b.nodeBound(spoke)
+	}
+	b.assign[spoke] = -1
+
+	order := make([]int, len(b.hubs))
+	for c := range order {
+		order[c] = c
+	}
+	sort.SliceStable(order, func(x, y int) bool { return children[order[x]] < children[order[y]] })
+
+	parent := b.bound
+	for _, c := range order {
+		if pruned(children[c], b.best.Cost) {
+			continue
+		}
+		if b.stopped {
+			b.leaveOpen(children[c])
+			continue
+		}
+		b.assign[spoke] = b.hubs[c]
+		b.bound = children[c]
+		b.branchAllocation(t + 1)
+	}
+	b.assign[spoke] = -1
+	b.bound = parent
+}
+
+// in holds the chosen hubs, order[depth:] the undecided nodes
+func (b *BranchAndBound) branchHubs(in []int, depth int) {
+	undecided := len(b.order) - depth
+	if len(in)+undecided < b.p {
+		return
+	}
+
+	candidates := append(append([]int{}, in...), b.order[depth:]...)
+	if len(in) == b.p {
+		candidates = in
+	}
+	bound := calcMultipleAllocationCost(candidates)
+	if pruned(bound, b.best.Cost) {
+		return
+	}
+
+	b.nodes++
+	if b.limitReached() {
+		b.leaveOpen(bound)
+		return
+	}
+
+	if len(in) == b.p {
+		// a spoke without a hub it may be allocated to has no allocation
+		if unallocatable(in) > 0 {
+			return
+		}
+		b.prepareAllocation(append([]int{}, in...))
+		b.branchAllocation(0)
+		return
+	}
+	if len(in)+undecided == b.p {
+		b.branchHubs(candidates, len(b.order))
+		return
+	}
+
+	v := b.order[depth]
+	b.branchHubs(append(append([]int{}, in...), v), depth+1)
+	b.branchHubs(in, depth+1)
+}
+
+// greedy start: add the hub that lowers the multiple allocation cost the
+// most to the forced hubs, then improve the nearest allocation by single
+// node moves
+func (b *BranchAndBound) initialSolution() {
+	hubs := append([]int{}, forcedHubs...)
+	for len(hubs) < b.p {
+		best_node, best_cost := -1, math.Inf(1)
+		for v := 0; v < b.n; v++ {
+			if isInSlice(v, hubs) || !canBeHub(v) {
+				continue
+			}
+			c := calcMultipleAllocationCost(append(append([]int{}, hubs...), v))
+			if c < best_cost {
+				best_node, best_cost = v, c
+			}
+		}
+		hubs = append(hubs, best_node)
+	}
+
+	solution, cost := improvedAllocation(hubs)
+	b.best = Result{Hubs: hubs, Solution: solution, Cost: cost}
+}
+
+// allocate nodes to their nearest hubs they may be allocated to
+func allocateNearest(hubs []int) []int {
+	solution := make([]int, len(cost_matrix))
+	for i := range solution {
+		allowed := allowedHubs(i, hubs)
+		target_hub := allowed[0]
+		for _, hub := range allowed {
+			if cost_matrix[i][hub] < cost_matrix[i][target_hub] {
+				target_hub = hub
+			}
+		}
+		solution[i] = target_hub
+	}
+	return solution
+}
+
+// nearest allocation to the hubs followed by single node reallocations
+// while the total cost improves, a hub set that leaves a spoke without an
+// allowed hub costs infinity
+func improvedAllocation(hubs []int) ([]int, float64) {
+	solution := allocateNearest(hubs)
+	if unallocatable(hubs) > 0 {
+		return solution, math.Inf(1)
+	}
+	cost := calcTotalCost(cost_matrix, flow_matrix, alpha, solution)
+	for improved := true; improved; {
+		improved = false
+		for i := range solution {
+			if isInSlice(i, hubs) {
+				continue
+			}
+			for _, hub := range allowedHubs(i, hubs) {
+				old := solution[i]
+				solution[i] = hub
+				c := calcTotalCost(cost_matrix, flow_matrix, alpha, solution)
+				if c < cost-1e-9 {
+					cost = c
+					improved = true
+				} else {
+					solution[i] = old
+				}
+			}
+		}
+	}
+	return solution, cost
+}
+
+// BranchAndBoundSolve finds the optimal single allocation p-hub median.
+// Hub sets are branched node by node and bounded by the multiple
+// allocation cost of the hubs still possible, the allocation of every
+// complete hub set is solved by a second branch and bound over the spokes.
+func BranchAndBoundSolve(number_of_hubs int) Result {
+	b := &BranchAndBound{
+		n:         len(cost_matrix),
+		p:         number_of_hubs,
+		openBound: math.Inf(1),
+		start:     time.Now(),
+	}
+	// forced hubs are chosen from the start, forbidden nodes never
+	for i := 0; i < b.n; i++ {
+		if canBeHub(i) && !isForced(i) {
+			b.order = append(b.order, i)
+		}
+	}
+	sort.SliceStable(b.order, func(x, y int) bool {
+		return nodeFlow(b.order[x]) > nodeFlow(b.order[y])
+	})
+
+	b.initialSolution()
+	b.branchHubs(append([]int{}, forcedHubs...), 0)
+
+	result := b.best
+	result.Nodes = b.nodes
+	result.Proven = !b.stopped
+	result.LowerBound = math.Min(result.Cost, b.openBound)
+	result.Elapsed = time.Since(b.start)
+	return result
+}
+
+var methodNames = map[string]string{
+	"bnb":        "branch_and_bound",
+	"lagrangian": "lagrangian",
+}
+
+func main() {
+
+	var err error
+
+	flag.IntVar(&maxNodes, "max-nodes", maxNodes, "largest instance solved, bigger data sets are skipped")
+	flag.IntVar(&nodeLimit, "node-limit", nodeLimit, "stop after this many branch and bound nodes, 0 for no limit")
+	flag.DurationVar(&timeLimit, "time-limit", timeLimit, "stop each configuration after this time, 0 for no limit")
+	flag.StringVar(&method, "method", method, "bnb for the exact branch and bound, lagrangian for the Lagrangian relaxation bounds")
+	flag.IntVar(&lagrangianIterations, "lagrangian-iterations", lagrangianIterations, "maximum subgradient iterations of the Lagrangian relaxation")
+	flag.StringVar(&boundsFile, "output", boundsFile, "CSV file the bounds are merged into, read by the heuristics to report their gap")
+	flag.StringVar(&constraintsFile, "hub-constraints", constraintsFile, "CSV of forced,NODE, forbidden,NODE and forbidden_allocation,SPOKE,HUB rows, nodes numbered from 1")
+	flag.StringVar(&forcedOption, "forced-hubs", forcedOption, "comma separated nodes, numbered from 1, that are always hubs")
+	flag.StringVar(&forbiddenOption, "forbidden-hubs", forbiddenOption, "comma separated nodes, numbered from 1, that are never hubs")
+	flag.StringVar(&forbiddenAllocationOption, "forbidden-allocations", forbiddenAllocationOption, "comma separated SPOKE:HUB pairs, numbered from 1, of allocations that are not allowed")
+	flag.Parse()
+
+	if method != "bnb" && method != "lagrangian" {
+		fmt.Printf("Error: unknown method %q\n", method)
+		return
+	}
+
+	err = loadHubConstraints()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	data_sets_flow := []string{
+		"Flow_matrix10.csv",
+		"Flow_matrix15.csv",
+		"Flow_matrix20.csv",
+		"Flow_matrix25.csv",
+		"postal_office_network_flow_25.csv",
+		"postal_office_network_flow_55.csv",
+	}
+	data_sets_cost := []string{
+		"Cost_matrix10.csv",
+		"Cost_matrix15.csv",
+		"Cost_matrix20.csv",
+		"Cost_matrix25.csv",
+		"postal_office_network_distance_25.csv",
+		"postal_office_network_distance_55.csv",
+	}
+	sizes := []int{
+		10,
+		15,
+		20,
+		25,
+		25,
+		55,
+	}
+
+	alphas := []float64{
+		0.2,
+		0.4,
+		0.8,
+	}
+	hubs := []int{
+		3,
+		4,
+	}
+
+	rows, err := readBounds(boundsFile)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	counter := "Nodes"
+	if method == "lagrangian" {
+		counter = "Iterations"
+		fmt.Printf("Confirguration: Method[%s]\tMax Nodes[%d]\tIterations[%d]\tTime Limit[%s]\n", method, maxNodes, lagrangianIterations, timeLimit)
+	} else {
+		fmt.Printf("Confirguration: Method[%s]\tMax Nodes[%d]\tNode Limit[%d]\tTime Limit[%s]\n", method, maxNodes, nodeLimit, timeLimit)
+	}
+	if hubConstraints() {
+		printHubConstraints()
+	}
+	fmt.Printf("%-40s\t%-10s\t%-10s\t%-20s\t%-20s\t%-20s\t%-20s\t%-10s\t%-20s\t%-20s\n", "Datset", "No Hubs", "Alpha", "Hub Locations", "TNC", "Lower Bound", "Gap", "Optimal", counter, "Time")
+	for i, _ := range data_sets_flow {
+		if sizes[i] > maxNodes {
+			continue
+		}
+		// data sets without the constrained nodes are skipped
+		if constrainedNodes() > sizes[i] {
+			continue
+		}
+		for _, hub := range hubs {
+			err = checkHubConstraints(sizes[i], hub)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err.Error())
+				return
+			}
+		}
+
+		// read input data
+		cost_matrix, err = read_matrix(data_sets_cost[i], sizes[i])
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			return
+		}
+
+		flow_matrix, err = read_matrix(data_sets_flow[i], sizes[i])
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			return
+		}
+
+		// calc total flow
+		total_flow = calcTotalFlow(flow_matrix)
+
+		for _, hub := range hubs {
+			no_hubs = hub
+			for _, alfa := range alphas {
+				alpha = alfa
+
+				fmt.Printf("%-40s\t%-10d\t%-10f\t", data_sets_cost[i], no_hubs, alpha)
+				var r Result
+				if method == "lagrangian" {
+					r = LagrangianRelaxation(no_hubs)
+				} else {
+					r = BranchAndBoundSolve(no_hubs)
+				}
+				tnc := r.Cost / total_flow
+				lower_bound := r.LowerBound / total_flow
+				gap := (tnc - lower_bound) / lower_bound * 100
+				fmt.Printf("%-v\t%-20f\t%-20f\t%-20s\t%-10t\t%-20d\t%-20s\n", r.Hubs, tnc, lower_bound, fmt.Sprintf("%.4f%%", gap), r.Proven, r.Nodes, r.Elapsed)
+
+				rows = mergeBound(rows, BoundRow{
+					Dataset:    data_sets_cost[i],
+					Hubs:       no_hubs,
+					Alpha:      alpha,
+					Method:     methodNames[method],
+					LowerBound: lower_bound,
+					UpperBound: tnc,
+					Proven:     r.Proven,
+					Locations:  r.Hubs,
+				})
+			}
+		}
+	}
+
+	// bounds under hub constraints do not hold for the unconstrained
+	// problem, they are reported but not written
+	if hubConstraints() {
+		return
+	}
+	err = writeBounds(boundsFile, rows)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+	}
+}
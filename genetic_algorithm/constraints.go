@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// hub fixing constraints: forced hubs are open in every solution, forbidden
+// hubs never and a forbidden allocation keeps a spoke off a hub. They are
+// read from a CSV file of forced,NODE / forbidden,NODE /
+// forbidden_allocation,SPOKE,HUB rows and from the flags, nodes are
+// numbered from 1.
+var constraintsFile = ""
+var forcedOption = ""
+var forbiddenOption = ""
+var forbiddenAllocationOption = ""
+
+var forcedHubs = []int{}
+var forbiddenHubs = []int{}
+var forbiddenAllocations = map[[2]int]bool{}
+
+func hubConstraints() bool {
+	return len(forcedHubs) > 0 || len(forbiddenHubs) > 0 || len(forbiddenAllocations) > 0
+}
+
+// loadHubConstraints reads the constraints file and the flags
+func loadHubConstraints() error {
+	if constraintsFile != "" {
+		f, err := os.Open(constraintsFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		r := csv.NewReader(f)
+		r.FieldsPerRecord = -1
+		records, err := r.ReadAll()
+		if err != nil {
+			return err
+		}
+		for n, record := range records {
+			if len(record) == 0 || strings.TrimSpace(record[0]) == "" {
+				continue
+			}
+			err = addHubConstraint(strings.TrimSpace(record[0]), record[1:])
+			if err != nil {
+				return fmt.Errorf("%s line %d: %s", constraintsFile, n+1, err.Error())
+			}
+		}
+	}
+
+	for kind, option := range map[string]string{"forced": forcedOption, "forbidden": forbiddenOption} {
+		if option == "" {
+			continue
+		}
+		for _, node := range strings.Split(option, ",") {
+			err := addHubConstraint(kind, []string{node})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if forbiddenAllocationOption != "" {
+		for _, pair := range strings.Split(forbiddenAllocationOption, ",") {
+			err := addHubConstraint("forbidden_allocation", strings.Split(pair, ":"))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	sort.Ints(forcedHubs)
+	sort.Ints(forbiddenHubs)
+
+	for _, node := range forcedHubs {
+		if isInSlice(node, forbiddenHubs) {
+			return fmt.Errorf("node %d is forced and forbidden", node+1)
+		}
+	}
+	return nil
+}
+
+func addHubConstraint(kind string, fields []string) error {
+	nodes := make([]int, len(fields))
+	for k, field := range fields {
+		node, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return err
+		}
+		if node < 1 {
+			return fmt.Errorf("nodes are numbered from 1, got %d", node)
+		}
+		nodes[k] = node - 1
+	}
+
+	switch kind {
+	case "forced", "forbidden":
+		if len(nodes) != 1 {
+			return fmt.Errorf("%s expects one node", kind)
+		}
+		if kind == "forced" && !isInSlice(nodes[0], forcedHubs) {
+			forcedHubs = append(forcedHubs, nodes[0])
+		}
+		if kind == "forbidden" && !isInSlice(nodes[0], forbiddenHubs) {
+			forbiddenHubs = append(forbiddenHubs, nodes[0])
+		}
+	case "forbidden_allocation":
+		if len(nodes) != 2 || nodes[0] == nodes[1] {
+			return fmt.Errorf("forbidden_allocation expects a spoke and another hub")
+		}
+		forbiddenAllocations[[2]int{nodes[0], nodes[1]}] = true
+	default:
+		return fmt.Errorf("unknown constraint %q", kind)
+	}
+	return nil
+}
+
+// the number of nodes a data set needs for the constraints
+func constrainedNodes() int {
+	n := 0
+	for _, node := range append(append([]int{}, forcedHubs...), forbiddenHubs...) {
+		if node+1 > n {
+			n = node + 1
+		}
+	}
+	for pair := range forbiddenAllocations {
+		for _, node := range pair {
+			if node+1 > n {
+				n = node + 1
+			}
+		}
+	}
+	return n
+}
+
+// checkHubConstraints tells whether the constraints fit a data set of n
+// nodes and p hubs, p is 0 when the number of hubs is free
+func checkHubConstraints(n, p int) error {
+	for _, node := range append(append([]int{}, forcedHubs...), forbiddenHubs...) {
+		if node >= n {
+			return fmt.Errorf("node %d of the hub constraints is beyond the %d nodes", node+1, n)
+		}
+	}
+	for pair := range forbiddenAllocations {
+		if pair[0] >= n || pair[1] >= n {
+			return fmt.Errorf("allocation %d:%d of the hub constraints is beyond the %d nodes", pair[0]+1, pair[1]+1, n)
+		}
+	}
+	if p > 0 && len(forcedHubs) > p {
+		return fmt.Errorf("%d forced hubs do not fit into %d hubs", len(forcedHubs), p)
+	}
+	if p > 0 && n-len(forbiddenHubs) < p {
+		return fmt.Errorf("only %d nodes may become one of %d hubs", n-len(forbiddenHubs), p)
+	}
+	return nil
+}
+
+func canBeHub(node int) bool {
+	return !isInSlice(node, forbiddenHubs)
+}
+
+func isForced(node int) bool {
+	return isInSlice(node, forcedHubs)
+}
+
+// a hub may be replaced by node unless the hub is forced or the node
+// forbidden
+func canSwapHub(node, hub int) bool {
+	return canBeHub(node) && !isForced(hub)
+}
+
+func canAllocate(spoke, hub int) bool {
+	return spoke == hub || !forbiddenAllocations[[2]int{spoke, hub}]
+}
+
+// the hubs spoke may be allocated to, all hubs when none is allowed so
+// every spoke stays routed
+func allowedHubs(spoke int, hubs []int) []int {
+	var allowed []int
+	for _, hub := range hubs {
+		if canAllocate(spoke, hub) {
+			allowed = append(allowed, hub)
+		}
+	}
+	if len(allowed) == 0 {
+		return hubs
+	}
+	return allowed
+}
+
+// spokes that have no hub they may be allocated to
+func unallocatable(hubs []int) int {
+	count := 0
+	for i := range cost_matrix {
+		allowed := false
+		for _, hub := range hubs {
+			if canAllocate(i, hub) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			count++
+		}
+	}
+	return count
+}
+
+// repairHubs drops forbidden and repeated hubs, adds missing forced hubs in
+// place of unforced ones and fills up with random allowed nodes. The
+// number of hubs is kept unless every hub is forced. intn draws the
+// random numbers.
+func repairHubs(hubs []int, intn func(n int) int) []int {
+	repaired := []int{}
+	for _, hub := range hubs {
+		if canBeHub(hub) && !isInSlice(hub, repaired) {
+			repaired = append(repaired, hub)
+		}
+	}
+	for _, node := range forcedHubs {
+		if isInSlice(node, repaired) {
+			continue
+		}
+		var unforced []int
+		for k, hub := range repaired {
+			if !isForced(hub) {
+				unforced = append(unforced, k)
+			}
+		}
+		// a free number of hubs grows when every hub is forced
+		if len(repaired) < len(hubs) || len(unforced) == 0 {
+			repaired = append(repaired, node)
+			continue
+		}
+		repaired[unforced[intn(len(unforced))]] = node
+	}
+	for len(repaired) < len(hubs) {
+		node := intn(len(cost_matrix))
+		if canBeHub(node) && !isInSlice(node, repaired) {
+			repaired = append(repaired, node)
+		}
+	}
+	return repaired
+}
+
+// decodeAllowedHubs takes the forced hubs and the allowed nodes of the
+// highest keys up to number_of_hubs hubs
+func decodeAllowedHubs(keys []float64, number_of_hubs int) []int {
+	nodes := make([]int, len(keys))
+	for i := range nodes {
+		nodes[i] = i
+	}
+	sort.SliceStable(nodes, func(a, b int) bool {
+		return keys[nodes[a]] > keys[nodes[b]]
+	})
+	hubs := append([]int{}, forcedHubs...)
+	for _, node := range nodes {
+		if len(hubs) == number_of_hubs {
+			break
+		}
+		if canBeHub(node) && !isForced(node) {
+			hubs = append(hubs, node)
+		}
+	}
+	return hubs
+}
+
+// printHubConstraints reports the constraints in the configuration header
+func printHubConstraints() {
+	labels := func(nodes []int) string {
+		s := make([]string, len(nodes))
+		for k, node := range nodes {
+			s[k] = strconv.Itoa(node + 1)
+		}
+		return strings.Join(s, " ")
+	}
+	fmt.Printf("Hub Constraints: Forced[%s]\tForbidden[%s]\tForbidden Allocations[%d]\n", labels(forcedHubs), labels(forbiddenHubs), len(forbiddenAllocations))
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"math/rand"
+)
+
+// Differential evolution configuration
+var dePopSize = 50
+var deWeight = 0.5
+var deCrossover = 0.9
+
+// the nodes with the number_of_hubs highest keys become the hubs, forced
+// hubs first and forbidden nodes skipped
+func decodeKeys(keys []float64, number_of_hubs int) []int {
+	return decodeAllowedHubs(keys, number_of_hubs)
+}
+
+// three distinct members other than i
+func pickThree(rng *rand.Rand, size, i int) (int, int, int) {
+	r := rng.Perm(size)
+	picked := []int{}
+	for _, k := range r {
+		if k != i {
+			picked = append(picked, k)
+		}
+		if len(picked) == 3 {
+			break
+		}
+	}
+	return picked[0], picked[1], picked[2]
+}
+
+// RunDE is a DE/rand/1/bin over random keys, one key per node decoded into
+// the hub set by decodeKeys
+func RunDE() Organism {
+	rng := newRand()
+	n := len(cost_matrix)
+
+	keys := make([][]float64, dePopSize)
+	members := make([]Organism, dePopSize)
+	var best Organism
+	for i := range keys {
+		keys[i] = make([]float64, n)
+		for d := range keys[i] {
+			keys[i][d] = rng.Float64()
+		}
+		members[i] = organismFromHubs(limitChanges(decodeKeys(keys[i], no_hubs), rng.Intn))
+		if members[i].Fitness > best.Fitness {
+			best = members[i]
+		}
+	}
+
+	for g := 0; g < generations; g++ {
+		if runTermination.Stop(g-best.Generation, 100, 1/best.Fitness) {
+			break
+		}
+
+		for i := range keys {
+			r1, r2, r3 := pickThree(rng, len(keys), i)
+			trial := make([]float64, n)
+			forced := rng.Intn(n)
+			for d := range trial {
+				if d == forced || rng.Float64() < deCrossover {
+					trial[d] = keys[r1][d] + deWeight*(keys[r2][d]-keys[r3][d])
+				} else {
+					trial[d] = keys[i][d]
+				}
+			}
+
+			candidate := organismFromHubs(limitChanges(decodeKeys(trial, no_hubs), rng.Intn))
+			if candidate.Fitness >= members[i].Fitness {
+				keys[i] = trial
+				members[i] = candidate
+				if candidate.Fitness > best.Fitness {
+					best = candidate
+					best.Generation = g
+				}
+			}
+		}
+		runTrace.Add(g, 1/getBest(members).Fitness, 1/best.Fitness, 0, populationDiversity(members))
+	}
+
+	return best
+}
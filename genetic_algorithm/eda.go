@@ -0,0 +1,102 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// Estimation of distribution configuration
+var edaSamples = 100
+var edaElite = 0.3
+var edaLearningRate = 0.5
+
+// draw number_of_hubs distinct nodes after the forced hubs, each draw
+// proportional to the remaining marginal probabilities of the nodes that
+// may become hubs
+func sampleHubs(rng *rand.Rand, marginals []float64, number_of_hubs int) []int {
+	hubs := append(make([]int, 0, number_of_hubs), forcedHubs...)
+	for len(hubs) < number_of_hubs {
+		total := 0.0
+		for i, p := range marginals {
+			if !isInSlice(i, hubs) && canBeHub(i) {
+				total += p
+			}
+		}
+		r := rng.Float64() * total
+		selected := -1
+		for i, p := range marginals {
+			if isInSlice(i, hubs) || !canBeHub(i) {
+				continue
+			}
+			selected = i
+			r -= p
+			if r <= 0 {
+				break
+			}
+		}
+		hubs = append(hubs, selected)
+	}
+	return hubs
+}
+
+// RunEDA is a univariate marginal distribution algorithm over "node is
+// hub", the marginals move towards the hub frequencies of the elite
+func RunEDA() Organism {
+	rng := newRand()
+	n := len(cost_matrix)
+
+	// keep every node reachable so the search does not freeze early
+	lower := 0.1 / float64(n)
+	upper := 1 - lower
+
+	marginals := make([]float64, n)
+	for i := range marginals {
+		marginals[i] = float64(no_hubs) / float64(n)
+	}
+
+	elite := int(float64(edaSamples) * edaElite)
+	if elite < 1 {
+		elite = 1
+	}
+
+	var best Organism
+	for g := 0; g < generations; g++ {
+		if runTermination.Stop(g-best.Generation, 100, 1/best.Fitness) {
+			break
+		}
+
+		samples := make([]Organism, edaSamples)
+		for k := range samples {
+			samples[k] = organismFromHubs(limitChanges(sampleHubs(rng, marginals, no_hubs), rng.Intn))
+		}
+		sort.Stable(OrganismVector(samples))
+		if samples[0].Fitness > best.Fitness {
+			best = samples[0]
+			best.Generation = g
+		}
+		runTrace.Add(g, 1/samples[0].Fitness, 1/best.Fitness, 0, populationDiversity(samples))
+
+		frequency := make([]float64, n)
+		for _, o := range samples[:elite] {
+			for _, h := range o.DNA.Hubs {
+				frequency[h] += 1 / float64(elite)
+			}
+		}
+		for i := range marginals {
+			marginals[i] = (1-edaLearningRate)*marginals[i] + edaLearningRate*frequency[i]
+			marginals[i] = clamp(marginals[i], lower, upper)
+		}
+	}
+
+	return best
+}
+
+func clamp(value, low, high float64) float64 {
+	if value < low {
+		return low
+	}
+	if value > high {
+		return high
+	}
+	return value
+}
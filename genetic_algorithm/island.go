@@ -0,0 +1,316 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// seed of the experiment, every run draws its own seed from seedSource so
+// a given seed reproduces the whole table. Zero seeds from the clock.
+var seed int64 = 0
+var seedSource *rand.Rand
+
+// Island model configuration
+var islands = 4
+var migrationInterval = 10
+var migrants = 2
+var migrationTopology = "ring"
+var islandMutationRates = ""
+var islandSelections = ""
+
+// Settings are the evolution parameters of a single population
+type Settings struct {
+	MutationRate   float64
+	Selection      string // roulette or tournament
+	TournamentSize int
+}
+
+func defaultSettings() Settings {
+	return Settings{
+		MutationRate:   MutationRate,
+		Selection:      "roulette",
+		TournamentSize: 3,
+	}
+}
+
+func initSeed() {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	seedSource = rand.New(rand.NewSource(seed))
+}
+
+// creates the random source of a single run
+func newRand() *rand.Rand {
+	if seedSource == nil {
+		initSeed()
+	}
+	return rand.New(rand.NewSource(seedSource.Int63()))
+}
+
+// pick the fittest of size random organisms
+func tournament(rng *rand.Rand, population []Organism, size int) Organism {
+	best := population[rng.Intn(len(population))]
+	for i := 1; i < size; i++ {
+		o := population[rng.Intn(len(population))]
+		if o.Fitness > best.Fitness {
+			best = o
+		}
+	}
+	return best
+}
+
+// breed the next generation with the selection scheme of the settings
+func nextGeneration(rng *rand.Rand, settings Settings, population []Organism, maxFitness float64) []Organism {
+	var pool []Organism
+	if settings.Selection != "tournament" {
+		pool = createPool(population, maxFitness)
+	}
+	return naturalSelection(rng, settings, pool, population)
+}
+
+// the settings of every island, the comma separated mutation rates and
+// selection schemes are cycled over the islands
+func islandSettings() ([]Settings, error) {
+	settings := make([]Settings, islands)
+	rates := []string{}
+	if islandMutationRates != "" {
+		rates = strings.Split(islandMutationRates, ",")
+	}
+	selections := []string{}
+	if islandSelections != "" {
+		selections = strings.Split(islandSelections, ",")
+	}
+	for i := range settings {
+		settings[i] = defaultSettings()
+		if len(rates) > 0 {
+			rate, err := strconv.ParseFloat(rates[i%len(rates)], 64)
+			if err != nil {
+				return settings, err
+			}
+			settings[i].MutationRate = rate
+		}
+		if len(selections) > 0 {
+			settings[i].Selection = selections[i%len(selections)]
+		}
+	}
+	return settings, nil
+}
+
+// organisms sent from one island to another after an epoch
+type migration struct {
+	From      int
+	Epoch     int
+	Organisms []Organism
+}
+
+// Island is a sub-population evolving in its own goroutine
+type Island struct {
+	Index      int
+	Settings   Settings
+	Population []Organism
+	Best       Organism
+	rng        *rand.Rand
+	inbox      chan migration
+	pending    []migration
+}
+
+// the destinations of every island for every epoch, drawn up front from
+// the run's random source so the exchange pattern does not depend on
+// goroutine scheduling
+func migrationSchedule(rng *rand.Rand, epochs int) [][][]int {
+	schedule := make([][][]int, epochs)
+	for e := range schedule {
+		schedule[e] = make([][]int, islands)
+		for i := 0; i < islands; i++ {
+			switch migrationTopology {
+			case "full":
+				for k := 0; k < islands; k++ {
+					if k != i {
+						schedule[e][i] = append(schedule[e][i], k)
+					}
+				}
+			case "random":
+				k := rng.Intn(islands - 1)
+				if k >= i {
+					k++
+				}
+				schedule[e][i] = []int{k}
+			default:
+				schedule[e][i] = []int{(i + 1) % islands}
+			}
+		}
+	}
+	return schedule
+}
+
+func (is *Island) evolve(from, generations int) {
+	for g := from; g < from+generations; g++ {
+		best := getBest(is.Population)
+		best.Generation = g
+		if best.Fitness > is.Best.Fitness {
+			is.Best = best
+		}
+		runTrace.Add(g, 1/best.Fitness, 1/is.Best.Fitness, 0, populationDiversity(is.Population))
+		is.Population = nextGeneration(is.rng, is.Settings, is.Population, best.Fitness)
+	}
+}
+
+// emigrants are copies of the fittest organisms of the island
+func (is *Island) emigrants() []Organism {
+	sorted := make([]Organism, len(is.Population))
+	copy(sorted, is.Population)
+	sort.Stable(OrganismVector(sorted))
+	n := migrants
+	if n > len(sorted) {
+		n = len(sorted)
+	}
+	out := make([]Organism, n)
+	for i := 0; i < n; i++ {
+		out[i] = sorted[i]
+		out[i].DNA = sorted[i].DNA.clone()
+		if sorted[i].Learned != nil {
+			out[i].Learned = sorted[i].Learned.clone()
+		}
+	}
+	return out
+}
+
+// wait for the migrations of the epoch and let the immigrants replace the
+// weakest organisms, in the order of the sending islands
+func (is *Island) receive(epoch, expected int) {
+	var arrived []migration
+	rest := is.pending[:0]
+	for _, m := range is.pending {
+		if m.Epoch == epoch {
+			arrived = append(arrived, m)
+		} else {
+			rest = append(rest, m)
+		}
+	}
+	is.pending = rest
+	for len(arrived) < expected {
+		m := <-is.inbox
+		if m.Epoch == epoch {
+			arrived = append(arrived, m)
+		} else {
+			is.pending = append(is.pending, m)
+		}
+	}
+	sort.Slice(arrived, func(a, b int) bool { return arrived[a].From < arrived[b].From })
+
+	sort.Stable(OrganismVector(is.Population))
+	last := len(is.Population) - 1
+	for _, m := range arrived {
+		for _, o := range m.Organisms {
+			if last < 0 {
+				return
+			}
+			is.Population[last] = o
+			last--
+		}
+	}
+}
+
+// RunIslands evolves several populations concurrently, every
+// migrationInterval generations each island sends its best organisms to
+// the islands given by the migration topology
+func RunIslands() Organism {
+	rng := newRand()
+	settings, _ := islandSettings()
+
+	epochs := (generations + migrationInterval - 1) / migrationInterval
+	schedule := migrationSchedule(rng, epochs)
+	sources := make([][]int, epochs)
+	received := make([]int, islands)
+	for e := range schedule {
+		sources[e] = make([]int, islands)
+		for _, destinations := range schedule[e] {
+			for _, k := range destinations {
+				sources[e][k]++
+				received[k]++
+			}
+		}
+	}
+
+	// the islands share the population size of the plain GA
+	size := PopSize / islands
+	if size < 2 {
+		size = 2
+	}
+
+	all := make([]*Island, islands)
+	for i := range all {
+		island_rng := rand.New(rand.NewSource(rng.Int63()))
+		population := make([]Organism, size)
+		for k := range population {
+			population[k] = createOrganism(island_rng, cost_matrix, flow_matrix, alpha, no_hubs)
+		}
+		if warmStart() {
+			population[0] = incumbentOrganism(island_rng, 0)
+		}
+		all[i] = &Island{
+			Index:      i,
+			Settings:   settings[i],
+			Population: population,
+			rng:        island_rng,
+			// room for every migration of the run so senders never block
+			inbox: make(chan migration, received[i]),
+		}
+	}
+
+	// the islands evolve an epoch concurrently and meet at the migration
+	// barrier, where the run decides on the islands' state whether it
+	// stops so a given seed stops at the same epoch. The island model has
+	// no stall limit of its own.
+	var fittest Organism
+	improved := 0
+	for e := 0; e < epochs; e++ {
+		from := e * migrationInterval
+		length := migrationInterval
+		if from+length > generations {
+			length = generations - from
+		}
+		var wg sync.WaitGroup
+		for _, is := range all {
+			wg.Add(1)
+			go func(is *Island) {
+				defer wg.Done()
+				is.evolve(from, length)
+			}(is)
+		}
+		wg.Wait()
+
+		for _, is := range all {
+			if is.Best.Fitness > fittest.Fitness {
+				fittest, improved = is.Best, is.Best.Generation
+			}
+		}
+		if runTermination.Stop(from+length-1-improved, -1, 1/fittest.Fitness) || e == epochs-1 {
+			break
+		}
+		for _, is := range all {
+			for _, k := range schedule[e][is.Index] {
+				all[k].inbox <- migration{From: is.Index, Epoch: e, Organisms: is.emigrants()}
+			}
+		}
+		for _, is := range all {
+			is.receive(e, sources[e][is.Index])
+		}
+	}
+
+	best := all[0].Best
+	for _, is := range all[1:] {
+		if is.Best.Fitness > best.Fitness {
+			best = is.Best
+		}
+	}
+	if best.Learned != nil {
+		best.DNA = best.Learned
+	}
+	return best
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	// "bytes"
+	"context"
+	"flag"
 	"fmt"
+	"math"
 	"math/rand"
 	"time"
 
@@ -10,9 +13,11 @@ import (
 	"encoding/csv"
 	"io"
 	"os"
+	"os/signal"
 	"strconv"
 
 	"sort"
+	"strings"
 	// "sync"
 	// "log"
 )
@@ -39,7 +44,11 @@ var aspiration = 300
 // }
 
 func read_matrix(location string, no_nodes int) (matrix [][]float64, err error) {
-	f, _ := os.Open(location)
+	f, err := os.Open(location)
+	if err != nil {
+		return matrix, err
+	}
+	defer f.Close()
 
 	// Create a new reader.
 	r := csv.NewReader(bufio.NewReader(f))
@@ -53,6 +62,12 @@ func read_matrix(location string, no_nodes int) (matrix [][]float64, err error)
 		if err == io.EOF {
 			break
 		}
+		if err != nil {
+			return matrix, err
+		}
+		if len(matrix) == no_nodes || len(record) != no_nodes {
+			return matrix, fmt.Errorf("%s is not a %d x %d matrix, row %d has %d columns", location, no_nodes, no_nodes, len(matrix)+1, len(record))
+		}
 
 		for i := 0; i < no_nodes; i++ {
 			value, err := strconv.ParseFloat(record[i], 10)
@@ -65,8 +80,11 @@ func read_matrix(location string, no_nodes int) (matrix [][]float64, err error)
 		matrix = append(matrix, row)
 		row = []float64{}
 	}
+	if len(matrix) != no_nodes {
+		return matrix, fmt.Errorf("%s is not a %d x %d matrix, it has %d rows", location, no_nodes, no_nodes, len(matrix))
+	}
 
-	return matrix, err
+	return matrix, nil
 }
 
 func calcTotalFlow(flow_matrix [][]float64) float64 {
@@ -88,21 +106,19 @@ func isInSlice(a int, list []int) bool {
 	return false
 }
 
-// func RunGA(writer *csv.Writer) Organism {
 func RunGA() Organism {
 	// start := time.Now()
-	rand.Seed(time.Now().UTC().UnixNano())
+	rng := newRand()
+	settings := defaultSettings()
 
 	// target := []byte("To be or not to be")
-	population := createPopulation(cost_matrix, flow_matrix, alpha, no_hubs)
+	population := createPopulation(rng, cost_matrix, flow_matrix, alpha, no_hubs)
 
 	generation := 0
 	iterations_since_best_oragnism := 0
 	var bestOragismFound Organism
 	bestOrganism := Organism{}
 
-	generation_best := make([]string, 0)
-
 	for i := 0; i < generations; i++ {
 		// i := 0
 		// for time.Since(start) < 150*time.Second {
@@ -114,29 +130,286 @@ func RunGA() Organism {
 			bestOragismFound = bestOrganism
 		} else {
 			iterations_since_best_oragnism++
-			if iterations_since_best_oragnism > 100 {
-				break
-			}
 		}
-		generation_best = append(generation_best, fmt.Sprintf("%f", 1/bestOrganism.Fitness))
+		runTrace.Add(i, 1/bestOrganism.Fitness, 1/bestOragismFound.Fitness, 0, populationDiversity(population))
+		if runTermination.Stop(iterations_since_best_oragnism, 100, 1/bestOragismFound.Fitness) {
+			break
+		}
 
-		maxFitness := bestOrganism.Fitness
-		pool := createPool(population, maxFitness)
-		population = naturalSelection(pool, population)
+		population = nextGeneration(rng, settings, population, bestOrganism.Fitness)
 
 		// elapsed := time.Since(start)
 		// fmt.Printf("\nTime taken: %s\n", elapsed)
 	}
-	// fmt.Printf("%+4v\n", generation_best)
-
-	// err := writer.Write(generation_best)
-	// checkError("Cannot write to file", err)
+	// under baldwinian learning the fitness belongs to the learned solution
+	if bestOragismFound.Learned != nil {
+		bestOragismFound.DNA = bestOragismFound.Learned
+	}
 	return bestOragismFound
 }
 
+var algorithmNames = []string{"ga", "memetic", "local-ts", "island", "de", "eda", "nsga2"}
+
+func isAlgorithm(name string) bool {
+	for _, n := range algorithmNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// runs the named algorithm once on the loaded instance
+func runAlgorithm(name string) Organism {
+	switch name {
+	case "local-ts":
+		return RunTS()
+	case "memetic":
+		memetic = true
+		defer func() { memetic = false }()
+		return RunGA()
+	case "island":
+		return RunIslands()
+	case "de":
+		return RunDE()
+	case "eda":
+		return RunEDA()
+	case "nsga2":
+		return RunNSGA()
+	}
+	return RunGA()
+}
+
 func main() {
 	var err error
 
+	algorithms := "ga"
+	flag.StringVar(&algorithms, "algorithms", algorithms, "comma separated algorithms to run on every configuration: ga, memetic, local-ts (the local tabu search of memetic on its own), island, de, eda, or nsga2 on its own")
+	flag.StringVar(&localSearch, "local-search", localSearch, "local search applied by the memetic algorithm: tabu or first")
+	flag.IntVar(&localSearchIterations, "ls-iterations", localSearchIterations, "iterations (tabu) or improving moves (first) of each local search")
+	flag.Float64Var(&localSearchFraction, "ls-fraction", localSearchFraction, "fraction of the offspring improved by the local search")
+	flag.StringVar(&learning, "learning", learning, "memetic learning: lamarckian or baldwinian")
+	flag.IntVar(&tsIterations, "ts-iterations", tsIterations, "iterations of the local-ts baseline")
+	flag.Int64Var(&seed, "seed", seed, "random seed of the experiment, 0 seeds from the clock")
+	flag.IntVar(&islands, "islands", islands, "number of islands of the island model")
+	flag.IntVar(&migrationInterval, "migration-interval", migrationInterval, "generations between two migrations")
+	flag.IntVar(&migrants, "migrants", migrants, "organisms sent by an island on every migration")
+	flag.StringVar(&migrationTopology, "migration-topology", migrationTopology, "destinations of the migrants: ring, full or random")
+	flag.StringVar(&islandMutationRates, "island-mutation-rates", islandMutationRates, "comma separated mutation rates cycled over the islands")
+	flag.StringVar(&islandSelections, "island-selections", islandSelections, "comma separated selection schemes cycled over the islands: roulette or tournament")
+	flag.IntVar(&dePopSize, "de-population", dePopSize, "population size of the differential evolution")
+	flag.Float64Var(&deWeight, "de-f", deWeight, "differential weight F of the differential evolution")
+	flag.Float64Var(&deCrossover, "de-cr", deCrossover, "crossover probability CR of the differential evolution")
+	flag.IntVar(&edaSamples, "eda-samples", edaSamples, "hub sets sampled per generation of the estimation of distribution algorithm")
+	flag.Float64Var(&edaElite, "eda-elite", edaElite, "fraction of the samples the distribution is estimated from")
+	flag.Float64Var(&edaLearningRate, "eda-learning-rate", edaLearningRate, "weight of the elite frequencies in the distribution update")
+	flag.StringVar(&boundsFile, "bounds", boundsFile, "CSV file of known bounds written by branch_and_bound, used to report the gap")
+	allocationOption := allocation
+	flag.StringVar(&allocationOption, "allocation", allocationOption, "allocation of the spokes: single, multiple or r=K for at most K hubs per spoke")
+	flag.StringVar(&capacityFile, "capacities", capacityFile, "CSV of capacity,fixed_cost per node, solves the capacitated problem on the data sets of that many nodes")
+	flag.Float64Var(&capacityPenalty, "capacity-penalty", capacityPenalty, "penalty per unit of flow over a hub capacity, relative to the largest cost")
+	flag.StringVar(&fixedCostFile, "fixed-costs", fixedCostFile, "CSV of the hub fixed cost per node (last column), the number of hubs is then chosen by the search")
+	flag.StringVar(&objective, "objective", objective, "objective: median (total cost), center (largest route cost) or covering (fewest hubs within -cover-radius)")
+	flag.Float64Var(&coverRadius, "cover-radius", coverRadius, "largest route cost of a covered O-D pair under the covering objective")
+	flag.StringVar(&hubNetwork, "hub-network", hubNetwork, "network between the hubs: complete, arcs (-hub-arcs arcs), tree or star")
+	flag.IntVar(&hubArcs, "hub-arcs", hubArcs, "number of hub arcs installed by the arcs hub network")
+	flag.StringVar(&scenarioFiles, "scenarios", scenarioFiles, "comma separated flow matrix files of the flow scenarios, they replace the flows of the data sets of that many nodes")
+	flag.StringVar(&intervalFiles, "flow-intervals", intervalFiles, "lower,upper flow matrix files of interval flows, turned into flow scenarios")
+	flag.IntVar(&intervalSamples, "interval-samples", intervalSamples, "random scenarios with every flow at one of its interval bounds")
+	flag.StringVar(&robustCriterion, "robust", robustCriterion, "criterion over the flow scenarios: expected, minmax or regret")
+	flag.StringVar(&constraintsFile, "hub-constraints", constraintsFile, "CSV of forced,NODE, forbidden,NODE and forbidden_allocation,SPOKE,HUB rows, nodes numbered from 1")
+	flag.StringVar(&forcedOption, "forced-hubs", forcedOption, "comma separated nodes, numbered from 1, that are always hubs")
+	flag.StringVar(&forbiddenOption, "forbidden-hubs", forbiddenOption, "comma separated nodes, numbered from 1, that are never hubs")
+	flag.StringVar(&forbiddenAllocationOption, "forbidden-allocations", forbiddenAllocationOption, "comma separated SPOKE:HUB pairs, numbered from 1, of allocations that are not allowed")
+	flag.StringVar(&initialFile, "initial-solution", initialFile, "solution file with Hubs: and Solution: lines, nodes numbered from 1, seeded into the populations")
+	flag.IntVar(&maxChanges, "max-changes", maxChanges, "most hubs that may differ from the initial solution, -1 for no limit")
+	flag.StringVar(&scaleModel, "scale-model", scaleModel, "inter-hub cost: constant (alpha), flowloc (piecewise linear in the hub pair flow) or threshold (alpha above -scale-threshold)")
+	flag.StringVar(&scaleBreakpoints, "scale-breakpoints", scaleBreakpoints, "comma separated hub pair flows, as fractions of the total flow, where the flowloc slope falls towards alpha")
+	flag.Float64Var(&scaleThreshold, "scale-threshold", scaleThreshold, "hub pair flow, as a fraction of the total flow, from which the threshold model discounts by alpha")
+	flag.StringVar(&paretoCSV, "pareto-csv", paretoCSV, "CSV file the Pareto fronts of nsga2 are written to")
+	flag.StringVar(&paretoJSON, "pareto-json", paretoJSON, "JSON file the Pareto fronts of nsga2 are written to")
+	flag.BoolVar(&reportText, "report", reportText, "report the cost breakdown, hub flows and costliest routes of the best solutions")
+	flag.StringVar(&reportJSON, "report-json", reportJSON, "JSON file the reports of the best solutions are written to")
+	flag.IntVar(&topRoutes, "top-routes", topRoutes, "number of the costliest O-D routes in the reports")
+	flag.StringVar(&traceDir, "trace-dir", traceDir, "directory the convergence trace of every run is written to")
+	flag.StringVar(&traceFormat, "trace-format", traceFormat, "format of the convergence traces: csv or jsonl")
+	flag.DurationVar(&timeLimit, "time-limit", timeLimit, "wall clock limit of every run, e.g. 30s")
+	flag.Int64Var(&maxEvaluations, "max-evaluations", maxEvaluations, "budget of cost evaluations of every run")
+	flag.Float64Var(&targetCost, "target-cost", targetCost, "normalized cost at which a run stops")
+	flag.IntVar(&stallLimit, "stall-limit", stallLimit, "generations without improvement after which a run stops, 0 keeps the limit of the algorithm")
+	flag.Parse()
+
+	// an interrupt stops the running search, the results so far are kept
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	rootContext = ctx
+
+	initSeed()
+
+	err = parseAllocation(allocationOption)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+	err = checkTermination()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+	err = checkTrace()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+	if topRoutes < 0 {
+		fmt.Printf("Error: the number of top routes must be 0 or more, got %d\n", topRoutes)
+		return
+	}
+
+	if capacityFile != "" {
+		capacities, fixedCosts, err = readNodeAttributes(capacityFile)
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			return
+		}
+		if allocation != "single" {
+			fmt.Printf("Error: capacities are only supported with single allocation\n")
+			return
+		}
+	}
+
+	if fixedCostFile != "" {
+		if capacitated() {
+			fmt.Printf("Error: the number of hubs of the capacitated problem is fixed, use the fixed costs of the capacities file\n")
+			return
+		}
+		fixedCosts, err = readFixedCosts(fixedCostFile)
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			return
+		}
+	}
+
+	err = checkObjective()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+	if objective != "median" && (capacityFile != "" || fixedCostFile != "") {
+		fmt.Printf("Error: capacities and fixed costs need the median objective\n")
+		return
+	}
+
+	err = parseScale()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+	if scaleModel != "constant" && (objective != "median" || allocation != "single") {
+		fmt.Printf("Error: economies of scale need the median objective and single allocation\n")
+		return
+	}
+
+	err = checkHubNetwork()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+	if incompleteNetwork() && (objective != "median" || allocation != "single" || scaleModel != "constant") {
+		fmt.Printf("Error: incomplete hub networks need the median objective, single allocation and a constant alpha\n")
+		return
+	}
+
+	err = loadScenarios()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+	if robust() && (objective != "median" || capacitated() || scaleModel != "constant") {
+		fmt.Printf("Error: flow scenarios need the median objective, no capacities and a constant alpha\n")
+		return
+	}
+
+	err = loadHubConstraints()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	if initialFile != "" {
+		err = loadIncumbent()
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			return
+		}
+	}
+
+	// the known bounds are single allocation median optima without fixed
+	// costs, with a constant alpha, a complete hub network, exact flows and
+	// no hub constraints
+	if objective == "median" && allocation == "single" && len(fixedCosts) == 0 && scaleModel == "constant" && !incompleteNetwork() && !robust() && !hubConstraints() {
+		err = loadBounds(boundsFile)
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			return
+		}
+	}
+
+	names := strings.Split(algorithms, ",")
+	for _, name := range names {
+		if !isAlgorithm(name) {
+			fmt.Printf("Error: unknown algorithm %q\n", name)
+			return
+		}
+		if name == "nsga2" {
+			if len(names) > 1 || objective != "median" || capacityFile != "" || incompleteNetwork() || robust() {
+				fmt.Printf("Error: nsga2 runs on its own with the median objective, without capacities, with a complete hub network and exact flows\n")
+				return
+			}
+			multiObjective = true
+		}
+		if freeHubs() && (name == "de" || name == "eda") {
+			fmt.Printf("Error: %s searches a fixed number of hubs\n", name)
+			return
+		}
+	}
+	if localSearch != "tabu" && localSearch != "first" {
+		fmt.Printf("Error: unknown local search %q\n", localSearch)
+		return
+	}
+	if learning != "lamarckian" && learning != "baldwinian" {
+		fmt.Printf("Error: unknown learning %q\n", learning)
+		return
+	}
+	if migrationTopology != "ring" && migrationTopology != "full" && migrationTopology != "random" {
+		fmt.Printf("Error: unknown migration topology %q\n", migrationTopology)
+		return
+	}
+	if islands < 2 || migrationInterval < 1 {
+		fmt.Printf("Error: the island model needs at least 2 islands and a positive migration interval\n")
+		return
+	}
+	// the mutation of a member takes three others
+	if dePopSize < 4 {
+		fmt.Printf("Error: the differential evolution needs a population of at least 4\n")
+		return
+	}
+	if edaSamples < 1 || edaElite <= 0 || edaElite > 1 {
+		fmt.Printf("Error: the estimation of distribution algorithm needs a sample and an elite fraction in (0, 1]\n")
+		return
+	}
+	settings, err := islandSettings()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+	for _, s := range settings {
+		if s.Selection != "roulette" && s.Selection != "tournament" {
+			fmt.Printf("Error: unknown selection %q\n", s.Selection)
+			return
+		}
+	}
+
 	alphas := []float64{
 		0.2,
 		0.4,
@@ -146,6 +419,13 @@ func main() {
 		3,
 		4,
 	}
+	// 0 lets the search choose the number of hubs, a warm start keeps the
+	// number of hubs of the incumbent
+	if freeHubs() {
+		hubs = []int{0}
+	} else if warmStart() {
+		hubs = []int{len(incumbentHubs)}
+	}
 
 	data_sets_flow := []string{
 		"Flow_matrix10.csv",
@@ -172,79 +452,236 @@ func main() {
 		55,
 	}
 
-	fmt.Printf("Confirguration: Mutataion Rate[%0.3f]\tPopulation Size[%d]\tGenerations[%d]\tAspiration[%d]\n", MutationRate, PopSize, generations, aspiration)
-	fmt.Printf("%-40s\t%-10s\t%-10s\t%-20s\t%-20s\t%-20s\t%-20s\t%-20s\t%-20s\n", "Datset", "No Hubs", "Alpha", "Hub Locations", "TNC", "Avg TNC", "Time Per Run", "Total Time", "Avg Generations")
+	fmt.Printf("Confirguration: Mutataion Rate[%0.3f]\tPopulation Size[%d]\tGenerations[%d]\tAspiration[%d]\tSeed[%d]\tAllocation[%s]\tObjective[%s]\n", MutationRate, PopSize, generations, aspiration, seed, allocationName(), objective)
+	if strings.Contains(algorithms, "island") {
+		fmt.Printf("Island Model: Islands[%d]\tMigration Interval[%d]\tMigrants[%d]\tTopology[%s]\tMutation Rates[%s]\tSelections[%s]\n", islands, migrationInterval, migrants, migrationTopology, islandMutationRates, islandSelections)
+	}
+	if strings.Contains(algorithms, "de") {
+		fmt.Printf("Differential Evolution: Population Size[%d]\tF[%0.3f]\tCR[%0.3f]\n", dePopSize, deWeight, deCrossover)
+	}
+	if strings.Contains(algorithms, "eda") {
+		fmt.Printf("Estimation of Distribution: Samples[%d]\tElite[%0.3f]\tLearning Rate[%0.3f]\n", edaSamples, edaElite, edaLearningRate)
+	}
+	if strings.Contains(algorithms, "memetic") || strings.Contains(algorithms, "local-ts") {
+		fmt.Printf("Local Search: Method[%s]\tIterations[%d]\tFraction[%0.3f]\tLearning[%s]\tTS Iterations[%d]\n", localSearch, localSearchIterations, localSearchFraction, learning, tsIterations)
+	}
+	if fixedCostFile != "" {
+		fmt.Printf("Fixed Costs: File[%s]\tNodes[%d]\n", fixedCostFile, len(fixedCosts))
+	}
+	if scaleModel == "flowloc" {
+		fmt.Printf("Economies of Scale: Model[%s]\tBreakpoints[%s]\n", scaleModel, scaleBreakpoints)
+	}
+	if scaleModel == "threshold" {
+		fmt.Printf("Economies of Scale: Model[%s]\tThreshold[%0.3f]\n", scaleModel, scaleThreshold)
+	}
+	if hubNetwork == "arcs" {
+		fmt.Printf("Hub Network: Shape[%s]\tArcs[%d]\n", hubNetwork, hubArcs)
+	} else if incompleteNetwork() {
+		fmt.Printf("Hub Network: Shape[%s]\n", hubNetwork)
+	}
+	if robust() {
+		fmt.Printf("Robust: Criterion[%s]\tScenarios[%d]\n", robustCriterion, len(scenarios))
+	}
+	if hubConstraints() {
+		printHubConstraints()
+	}
+	if warmStart() {
+		printWarmStart()
+	}
+	if capacitated() {
+		fmt.Printf("Capacities: File[%s]\tNodes[%d]\tPenalty[%0.3f]\n", capacityFile, len(capacities), capacityPenalty)
+	}
+	if terminationLimits() {
+		printTermination()
+	}
+	fmt.Printf("%-40s\t%-10s\t%-10s\t%-10s\t%-20s\t%-20s\t%-20s\t%-20s\t%-20s\t%-20s\t%-20s\t%-20s\n", "Datset", "Algorithm", "No Hubs", "Alpha", "Hub Locations", objectiveColumn(), "Gap", "Avg "+objectiveColumn(), "Time Per Run", "Total Time", "Avg Generations", "Stop Reason")
+datasets:
 	for i, _ := range data_sets_flow {
+		if len(fixedCosts) > 0 && len(fixedCosts) != sizes[i] {
+			continue
+		}
+		if robust() && len(scenarios[0]) != sizes[i] {
+			continue
+		}
+		if warmStart() && len(incumbentSolution) != sizes[i] {
+			continue
+		}
+		// data sets without the constrained nodes are skipped
+		if constrainedNodes() > sizes[i] {
+			continue
+		}
+		for _, hub := range hubs {
+			err = checkHubConstraints(sizes[i], hub)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err.Error())
+				return
+			}
+		}
+
 		cost_matrix, err = read_matrix(data_sets_cost[i], sizes[i])
 		if err != nil {
-			fmt.Printf("Error", err.Error())
+			fmt.Printf("Error: %s\n", err.Error())
 			return
 		}
 
 		// flow_matrix, err = read_matrix("postal_office_network_flow_55.csv", 55)
 		flow_matrix, err = read_matrix(data_sets_flow[i], sizes[i])
 		if err != nil {
-			fmt.Printf("Error", err.Error())
+			fmt.Printf("Error: %s\n", err.Error())
 			return
 		}
 
 		total_flow = calcTotalFlow(flow_matrix)
+		if robust() {
+			flow_matrix = meanScenario()
+			total_flow = calcTotalFlow(flow_matrix)
+		}
 		for _, hub := range hubs {
 			no_hubs = hub
 			for _, alfa := range alphas {
 				alpha = alfa
-				primary_start_time := time.Now()
-				var best []Organism
 
-				fmt.Printf("%-40s\t", data_sets_cost[i])
-				fmt.Printf("%-10d\t", no_hubs)
-				fmt.Printf("%-10f\t", alpha)
-
-				// file, err := os.Create(fmt.Sprintf("%s_%d_%f_results.csv", data_sets_cost[i], no_hubs, alpha))
-				// checkError("Cannot create file", err)
-
-				// writer := csv.NewWriter(file)
-
-				for k := 0; k < no_routines; k++ {
-					start := time.Now()
-					// c := RunGA(writer)
-					c := RunGA()
-					elapsed := time.Since(start)
-					c.DNA.ElapsedTime = elapsed
-					best = append(best, c)
+				// the regret is measured against the best cost of every
+				// scenario on its own, found by the stand alone tabu search
+				if robust() && robustCriterion == "regret" {
+					scenarioOptima = solveScenarios(func() float64 {
+						optimum := math.Inf(1)
+						for k := 0; k < no_routines; k++ {
+							optimum = math.Min(optimum, RunTS().DNA.Cost*total_flow)
+						}
+						return optimum
+					})
 				}
-				// writer.Flush()
-				// file.Close()
-
-				sort.Sort(OrganismVector(best))
-
-				// average TNC
-				average_tnc := 0.0
-				average_generations := 0
-				for _, c := range best {
-					average_tnc += c.DNA.Cost
-					average_generations += c.Generation
+				for _, name := range names {
+					primary_start_time := time.Now()
+					var best []Organism
+					currentFront = currentFront[:0]
+
+					for k := 0; k < no_routines; k++ {
+						start := time.Now()
+						if traceDir != "" {
+							runTrace = newTrace()
+						}
+						runTermination = newTermination(rootContext)
+						c := runAlgorithm(name)
+						elapsed := time.Since(start)
+						c.DNA.ElapsedTime = elapsed
+						c.StopReason = runTermination.Done()
+						runTermination = nil
+						best = append(best, c)
+						if runTrace != nil {
+							err = writeTrace(traceFile(data_sets_cost[i], name, no_hubs, k), runTrace)
+							runTrace = nil
+							if err != nil {
+								fmt.Printf("Error: %s\n", err.Error())
+								return
+							}
+						}
+					}
+
+					sort.Sort(OrganismVector(best))
+					// a run past the max changes is never the best
+					sort.SliceStable(best, func(a, b int) bool {
+						return withinChanges(best[a].DNA.Hubs) && !withinChanges(best[b].DNA.Hubs)
+					})
+
+					// average TNC
+					average_tnc := 0.0
+					average_generations := 0
+					for _, c := range best {
+						average_tnc += c.DNA.Cost
+						average_generations += c.Generation
+					}
+					average_tnc = average_tnc / float64(len(best))
+					average_generations = average_generations / len(best)
+					// the local tabu search has no generations
+					generations_column := strconv.Itoa(average_generations)
+					if name == "local-ts" {
+						generations_column = "-"
+					}
+					gap := formatGap(data_sets_cost[i], no_hubs, alpha, 1/best[0].Fitness)
+					fmt.Printf("%-40s\t", data_sets_cost[i])
+					fmt.Printf("%-10s\t", name)
+					fmt.Printf("%-10d\t", len(best[0].DNA.Hubs))
+					fmt.Printf("%-10f\t", alpha)
+					fmt.Printf("%-v\t%-20f\t%-20s\t%-20f\t%-20s\t%-20s\t%-20s\t%-20s\n", best[0].DNA.Hubs, 1/best[0].Fitness, gap, average_tnc, best[0].DNA.ElapsedTime, time.Since(primary_start_time), generations_column, best[0].StopReason)
+					if capacitated() {
+						printUtilisation(best[0].DNA.Solution, best[0].DNA.Hubs)
+					}
+					if objective == "covering" {
+						printCoverage(best[0].DNA.Solution, best[0].DNA.Hubs, best[0].DNA.Links)
+					}
+					if incompleteNetwork() {
+						printArcs(best[0].DNA.Hubs, best[0].DNA.Arcs)
+					}
+					if robust() {
+						printScenarios(best[0].DNA.Solution, best[0].DNA.Hubs, best[0].DNA.Links, best[0].DNA.Arcs)
+					}
+					if warmStart() {
+						o := incumbentOrganism(nil, 0)
+						fmt.Printf("%-40s\tIncumbent[%f]\tImprovement[%f]\tChanged Hubs[%d]\tWithin Max Changes[%t]\n", "", o.DNA.Cost, o.DNA.Cost-1/best[0].Fitness, hubChanges(best[0].DNA.Hubs), withinChanges(best[0].DNA.Hubs))
+					}
+					if reportText || reportJSON != "" {
+						r := buildReport(data_sets_cost[i], name, 1/best[0].Fitness, best[0].DNA.Solution, best[0].DNA.Hubs, best[0].DNA.Links, best[0].DNA.Arcs)
+						if reportText {
+							r.Print()
+						}
+						reports = append(reports, r)
+					}
+					if name == "nsga2" {
+						result := paretoResult(data_sets_cost[i], currentFront)
+						paretoResults = append(paretoResults, result)
+						fmt.Printf("%-40s\tPareto Front[%d]\tHypervolume[%f]\n", "", len(result.Front), result.Hypervolume)
+					}
+					// after an interrupt the remaining configurations are left out
+					if rootContext.Err() != nil {
+						break datasets
+					}
 				}
-				average_tnc = average_tnc / float64(len(best))
-				average_generations = average_generations / len(best)
-				fmt.Printf("%-v\t%-20f\t%-20f\t%-20s\t%-20s\t%-20d\n", best[0].DNA.Hubs, 1/best[0].Fitness, average_tnc, best[0].DNA.ElapsedTime, time.Since(primary_start_time), average_generations)
-
 			}
 		}
 	}
+
+	if paretoCSV != "" {
+		err = writeParetoCSV(paretoCSV, paretoResults)
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			return
+		}
+	}
+	if paretoJSON != "" {
+		err = writeParetoJSON(paretoJSON, paretoResults)
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			return
+		}
+	}
+	if reportJSON != "" {
+		err = writeReportJSON(reportJSON, reports)
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			return
+		}
+	}
 }
 
-//DNA
+// DNA
 type SolutionDNA struct {
 	Solution    []int
 	Hubs        []int
-	Cost        float64 // total cost
+	Cost        float64  // total cost
+	Links       [][]int  // hubs of every node under r-allocation
+	Arcs        [][2]int // installed hub arcs of an incomplete hub network
 	ElapsedTime time.Duration
 }
 
 func (c SolutionDNA) Print() {
 	// fmt.Fprintln(os.Stderr, "")
-	fmt.Printf("Hubs: %+v\n", c.Hubs)
+	hubs := make([]string, len(c.Hubs))
+	for k, hub := range c.Hubs {
+		hubs[k] = strconv.Itoa(hub + 1)
+	}
+	fmt.Printf("Hubs: %s\n", strings.Join(hubs, " "))
 	fmt.Printf("Nodes:  \t")
 	for i, _ := range c.Solution {
 		fmt.Printf("%-2d\t", i+1)
@@ -254,6 +691,22 @@ func (c SolutionDNA) Print() {
 	for _, n := range c.Solution {
 		fmt.Printf("%-2d\t", n+1)
 	}
+	if c.Links != nil {
+		fmt.Printf("\nLinks:  \t")
+		for _, l := range c.Links {
+			labels := make([]string, len(l))
+			for k, hub := range l {
+				labels[k] = strconv.Itoa(hub + 1)
+			}
+			fmt.Printf("%-2s\t", strings.Join(labels, ","))
+		}
+	}
+	if c.Arcs != nil {
+		fmt.Printf("\nHub Arcs:\t")
+		for _, a := range c.Arcs {
+			fmt.Printf("%d-%d\t", a[0]+1, a[1]+1)
+		}
+	}
 	fmt.Printf("\nTotal Cost: %+v\n", c.Cost)
 	fmt.Printf("Normalized Cost: %+v\n", c.Cost/total_flow)
 
@@ -264,6 +717,8 @@ type Organism struct {
 	DNA        *SolutionDNA
 	Fitness    float64 // normalized cost
 	Generation int
+	Learned    *SolutionDNA // local optimum of DNA under baldwinian learning
+	StopReason string
 }
 
 type OrganismVector []Organism
@@ -280,8 +735,21 @@ func (c OrganismVector) Swap(i, j int) {
 	c[j], c[i] = c[i], c[j]
 }
 
-// creates a Organism
-func createOrganism(cost_matrix, flow_matrix [][]float64, alpha float64, number_of_hubs int) (organism Organism) {
+// creates a Organism, near the incumbent when a warm start limits the
+// changed hubs
+func createOrganism(rng *rand.Rand, cost_matrix, flow_matrix [][]float64, alpha float64, number_of_hubs int) (organism Organism) {
+	if warmStart() && maxChanges >= 0 {
+		return incumbentOrganism(rng, rng.Intn(maxChanges+1))
+	}
+
+	// a free number of hubs lies between two and a quarter of the nodes and
+	// takes at least the forced hubs
+	if number_of_hubs == 0 {
+		number_of_hubs = 2 + rng.Intn(len(cost_matrix)/4)
+		if number_of_hubs < len(forcedHubs) {
+			number_of_hubs = len(forcedHubs)
+		}
+	}
 
 	organism = Organism{}
 	organism.DNA = &SolutionDNA{}
@@ -289,58 +757,129 @@ func createOrganism(cost_matrix, flow_matrix [][]float64, alpha float64, number_
 	organism.DNA.Solution = make([]int, len(cost_matrix))
 
 	// randomly select certain number of hubs
-	rand.Seed(time.Now().UnixNano())
-	for i := 0; i < number_of_hubs; i++ {
-		random_number := rand.Intn(len(cost_matrix))
-		if !isInSlice(random_number, organism.DNA.Hubs) {
+	for i := 0; i < number_of_hubs; {
+		random_number := rng.Intn(len(cost_matrix))
+		if !isInSlice(random_number, organism.DNA.Hubs[:i]) {
 			organism.DNA.Hubs[i] = random_number
+			i++
 		}
 	}
+	if hubConstraints() {
+		organism.DNA.Hubs = repairHubs(organism.DNA.Hubs, rng.Intn)
+	}
+
+	organism.DNA.Solution = allocateHubs(organism.DNA.Hubs)
+	organism.DNA.Links = allocateRLinks(organism.DNA.Hubs)
+	organism.DNA.Arcs = buildArcs(organism.DNA.Hubs)
+
+	organism.calcFitness()
+
+	return organism
+}
+
+// the incumbent of the warm start with up to changes of its unforced hubs
+// moved to random nodes, the spokes keep their hubs unless a hub moved
+func incumbentOrganism(rng *rand.Rand, changes int) Organism {
+	organism := Organism{DNA: &SolutionDNA{}}
+	organism.DNA.Hubs, organism.DNA.Solution = incumbent()
+	moved := false
+	for c := 0; c < changes; c++ {
+		k := rng.Intn(len(organism.DNA.Hubs))
+		node := rng.Intn(len(cost_matrix))
+		if isForced(organism.DNA.Hubs[k]) || !canBeHub(node) || isInSlice(node, organism.DNA.Hubs) {
+			continue
+		}
+		organism.DNA.Hubs[k] = node
+		moved = true
+	}
+	if moved {
+		organism.DNA.Solution = allocateHubs(organism.DNA.Hubs)
+	}
+	organism.DNA.Links = allocateRLinks(organism.DNA.Hubs)
+	organism.DNA.Arcs = buildArcs(organism.DNA.Hubs)
+	organism.calcFitness()
+	return organism
+}
 
-	// allocate nodes to their nearest organism.DNA.Hubs
+// moves the hubs back to the incumbent and allocates the nodes again when
+// more than maxChanges of them differ from it
+func (d *Organism) limitChanges(rng *rand.Rand) {
+	if withinChanges(d.DNA.Hubs) {
+		return
+	}
+	d.DNA.Hubs = limitChanges(d.DNA.Hubs, rng.Intn)
+	d.DNA.Solution = allocateHubs(d.DNA.Hubs)
+	d.DNA.Links = allocateRLinks(d.DNA.Hubs)
+	d.DNA.Arcs = buildArcs(d.DNA.Hubs)
+}
+
+// allocate nodes to their nearest hubs they may be allocated to
+func allocateNearest(hubs []int) []int {
+	solution := make([]int, len(cost_matrix))
 	for i, _ := range cost_matrix {
-		target_hub := organism.DNA.Hubs[0]
-		for _, hub := range organism.DNA.Hubs {
+		allowed := allowedHubs(i, hubs)
+		target_hub := allowed[0]
+		for _, hub := range allowed {
 			if cost_matrix[i][hub] < cost_matrix[i][target_hub] {
 				target_hub = hub
 			}
 		}
-		organism.DNA.Solution[i] = target_hub
+		solution[i] = target_hub
 	}
+	return solution
+}
 
-	organism.calcFitness()
+// creates an Organism from a hub set with the spokes at their nearest hub
+// the spokes of the hubs, nearest or within the hub capacities
+func allocateHubs(hubs []int) []int {
+	if capacitated() {
+		return allocateCapacitated(hubs)
+	}
+	return allocateNearest(hubs)
+}
+
+// the hub links under r-allocation, nil under the other strategies
+func allocateRLinks(hubs []int) [][]int {
+	if allocation != "r" {
+		return nil
+	}
+	return allocateLinks(hubs)
+}
 
+func organismFromHubs(hubs []int) Organism {
+	organism := Organism{DNA: &SolutionDNA{}}
+	organism.DNA.Hubs = hubs
+	organism.DNA.Solution = allocateHubs(hubs)
+	organism.DNA.Links = allocateRLinks(hubs)
+	organism.DNA.Arcs = buildArcs(hubs)
+	organism.calcFitness()
 	return organism
 }
 
 // creates the initial population
-func createPopulation(cost_matrix, flow_matrix [][]float64, alpha float64, number_of_hubs int) (population []Organism) {
+func createPopulation(rng *rand.Rand, cost_matrix, flow_matrix [][]float64, alpha float64, number_of_hubs int) (population []Organism) {
 	population = make([]Organism, PopSize)
 	for i := 0; i < PopSize; i++ {
-		population[i] = createOrganism(cost_matrix, flow_matrix, alpha, number_of_hubs)
+		population[i] = createOrganism(rng, cost_matrix, flow_matrix, alpha, number_of_hubs)
+	}
+	// a warm start seeds the incumbent
+	if warmStart() {
+		population[0] = incumbentOrganism(rng, 0)
 	}
 	return
 }
 
 // calculates the fitness of the Organism
 func (d *Organism) calcFitness() {
-	var total_cost float64
-
-	solution := d.DNA.Solution
-
-	total_cost = 0.0
-	for i, _ := range flow_matrix {
-		for j, _ := range flow_matrix {
-			collection_cost := flow_matrix[i][j] * cost_matrix[i][solution[i]]
-			transportation_cost := flow_matrix[i][j] * cost_matrix[solution[i]][solution[j]] * alpha
-			distribution_cost := flow_matrix[i][j] * cost_matrix[solution[j]][j]
-			cost := collection_cost + transportation_cost + distribution_cost
-			total_cost += cost
-		}
+	if d.Learned != nil {
+		d.Fitness = 1 / d.Learned.Cost
+		return
 	}
 
-	d.Fitness = 1 / (total_cost / total_flow)
-	d.DNA.Cost = total_cost / total_flow
+	total_cost := calcSolutionCost(d.DNA.Solution, d.DNA.Hubs, d.DNA.Links, d.DNA.Arcs)
+
+	d.Fitness = 1 / normalize(total_cost)
+	d.DNA.Cost = normalize(total_cost)
 	return
 }
 
@@ -370,29 +909,59 @@ func (d *Organism) isValid() bool {
 }
 
 // perform natural selection to create the next generation
-func naturalSelection(pool []Organism, population []Organism) []Organism {
+func naturalSelection(rng *rand.Rand, settings Settings, pool []Organism, population []Organism) []Organism {
 	next := make([]Organism, len(population))
 	for i := 0; i < len(population); i++ {
-		r1, r2 := rand.Intn(len(pool)), rand.Intn(len(pool))
-		a := pool[r1]
-		b := pool[r2]
+		var a, b Organism
+		if settings.Selection == "tournament" {
+			a = tournament(rng, population, settings.TournamentSize)
+			b = tournament(rng, population, settings.TournamentSize)
+		} else {
+			r1, r2 := rng.Intn(len(pool)), rng.Intn(len(pool))
+			a = pool[r1]
+			b = pool[r2]
+		}
 
-		child := crossover(a, b)
-		child.mutate()
+		child := crossover(rng, a, b)
+		child.mutate(rng, settings.MutationRate)
 
 		if !child.isValid() {
-			child = createOrganism(cost_matrix, flow_matrix, alpha, no_hubs)
+			child = createOrganism(rng, cost_matrix, flow_matrix, alpha, no_hubs)
 		}
 
 		child.calcFitness()
 
+		if memetic && rng.Float64() < localSearchFraction {
+			child.improve(rng)
+		}
+
 		next[i] = child
 	}
 	return next
 }
 
+// one point crossover of hub lists of any length, the head of one parent
+// joins the tail of the other without repeating a hub. Short children are
+// filled up from the parents to keep two hubs.
+func crossoverHubs(rng *rand.Rand, h1, h2 []int) []int {
+	hubs := append([]int{}, h1[:rng.Intn(len(h1)+1)]...)
+	for _, h := range h2[rng.Intn(len(h2)+1):] {
+		if !isInSlice(h, hubs) {
+			hubs = append(hubs, h)
+		}
+	}
+	for _, parent := range [][]int{h1, h2} {
+		for _, h := range parent {
+			if len(hubs) < 2 && !isInSlice(h, hubs) {
+				hubs = append(hubs, h)
+			}
+		}
+	}
+	return hubs
+}
+
 // crosses over 2 Organisms
-func crossover(d1 Organism, d2 Organism) Organism {
+func crossover(rng *rand.Rand, d1 Organism, d2 Organism) Organism {
 	dna := SolutionDNA{}
 
 	dna.Hubs = make([]int, len(d1.DNA.Hubs))
@@ -403,44 +972,139 @@ func crossover(d1 Organism, d2 Organism) Organism {
 		Fitness: 0,
 	}
 
-	mid := rand.Intn(len(d1.DNA.Hubs))
-	for i := 0; i < len(d1.DNA.Hubs); i++ {
-		if i > mid {
-			child.DNA.Hubs[i] = d1.DNA.Hubs[i]
-		} else {
-			child.DNA.Hubs[i] = d2.DNA.Hubs[i]
-		}
-	}
-
-	// allocate nodes to their nearest organism.DNA.Hubs
-	for i, _ := range cost_matrix {
-		target_hub := child.DNA.Hubs[0]
-		for _, hub := range child.DNA.Hubs {
-			if cost_matrix[i][hub] < cost_matrix[i][target_hub] {
-				target_hub = hub
+	// the child inherits the hub arcs of the first parent with the hubs of
+	// the second relinked in place, a free number of hubs inherits the arcs
+	// of both parents between its hubs
+	mid := rng.Intn(len(d1.DNA.Hubs))
+	arcs := cloneArcs(d1.DNA.Arcs)
+	if freeHubs() {
+		child.DNA.Hubs = crossoverHubs(rng, d1.DNA.Hubs, d2.DNA.Hubs)
+		arcs = append(arcs, d2.DNA.Arcs...)
+	} else {
+		for i := 0; i < len(d1.DNA.Hubs); i++ {
+			if i > mid {
+				child.DNA.Hubs[i] = d1.DNA.Hubs[i]
+			} else {
+				child.DNA.Hubs[i] = d2.DNA.Hubs[i]
 			}
 		}
-		child.DNA.Solution[i] = target_hub
+		// relinked over placeholders so a hub moved onto another moved
+		// hub is not moved twice
+		for i := 0; i <= mid; i++ {
+			relinkArcs(arcs, d1.DNA.Hubs[i], -1-i)
+		}
+		for i := 0; i <= mid; i++ {
+			relinkArcs(arcs, -1-i, child.DNA.Hubs[i])
+		}
+	}
+	if hubConstraints() {
+		child.DNA.Hubs = repairHubs(child.DNA.Hubs, rng.Intn)
 	}
+	child.DNA.Hubs = limitChanges(child.DNA.Hubs, rng.Intn)
+
+	child.DNA.Solution = allocateHubs(child.DNA.Hubs)
+	child.DNA.Links = allocateRLinks(child.DNA.Hubs)
+	child.DNA.Arcs = repairArcs(arcs, child.DNA.Hubs)
 
 	return child
 }
 
 // mutate the Organism
-func (d *Organism) mutate() {
+func (d *Organism) mutate(rng *rand.Rand, rate float64) {
+	defer d.limitChanges(rng)
+	// a free number of hubs grows and shrinks, a mutated node is opened or
+	// closed while two hubs and one spoke remain. Forced hubs stay open and
+	// forbidden nodes closed.
+	if freeHubs() {
+		changed := false
+		for i := 0; i < len(d.DNA.Solution); i++ {
+			if rng.Float64() >= rate {
+				continue
+			}
+			closed := false
+			for k, hub := range d.DNA.Hubs {
+				if hub == i && len(d.DNA.Hubs) > 2 && !isForced(hub) {
+					d.DNA.Hubs = append(d.DNA.Hubs[:k], d.DNA.Hubs[k+1:]...)
+					closed, changed = true, true
+					break
+				}
+			}
+			if !closed && !isInSlice(i, d.DNA.Hubs) && canBeHub(i) && len(d.DNA.Hubs) < len(d.DNA.Solution)-1 {
+				d.DNA.Hubs = append(d.DNA.Hubs, i)
+				changed = true
+			}
+		}
+		if changed {
+			d.DNA.Solution = allocateHubs(d.DNA.Hubs)
+			d.DNA.Links = allocateRLinks(d.DNA.Hubs)
+			d.DNA.Arcs = buildArcs(d.DNA.Hubs)
+		}
+	}
+	// under multiple allocation only the hubs matter, a mutated node takes
+	// the place of a random hub that is not forced
+	if allocation == "multiple" {
+		for i := 0; i < len(d.DNA.Solution); i++ {
+			if rng.Float64() < rate && !isInSlice(i, d.DNA.Hubs) {
+				if k := rng.Intn(len(d.DNA.Hubs)); canSwapHub(i, d.DNA.Hubs[k]) {
+					d.DNA.Hubs[k] = i
+				}
+			}
+		}
+		d.DNA.Solution = allocateNearest(d.DNA.Hubs)
+		return
+	}
+	// under r-allocation a mutated spoke adds, removes or swaps a hub link
+	if allocation == "r" {
+		for i := 0; i < len(d.DNA.Solution); i++ {
+			if rng.Float64() < rate && !isInSlice(i, d.DNA.Hubs) {
+				moveLink(d.DNA.Links, d.DNA.Hubs, i, rng.Intn)
+				d.DNA.Solution[i] = d.DNA.Links[i][0]
+			}
+		}
+		return
+	}
+	// every hub of an incomplete hub network may move a hub arc
+	if incompleteNetwork() {
+		for range d.DNA.Hubs {
+			if rng.Float64() < rate {
+				d.DNA.Arcs, _ = moveArc(d.DNA.Arcs, d.DNA.Hubs, rng.Intn)
+			}
+		}
+	}
 	for i := 0; i < len(d.DNA.Solution); i++ {
-		if rand.Float64() < MutationRate {
-			d.DNA.Solution[i] = d.DNA.Hubs[rand.Intn(len(d.DNA.Hubs))]
+		if rng.Float64() < rate {
+			allowed := allowedHubs(i, d.DNA.Hubs)
+			d.DNA.Solution[i] = allowed[rng.Intn(len(allowed))]
+			// capacitated spokes prefer a hub with room for their flow
+			if capacitated() && !isInSlice(i, d.DNA.Hubs) {
+				if hub := hubWithRoom(d.DNA.Solution, d.DNA.Hubs, i, rng.Intn); hub != -1 {
+					d.DNA.Solution[i] = hub
+				}
+			}
 		}
 	}
 }
 
+// share of distinct hub sets in the population
+func populationDiversity(population []Organism) float64 {
+	if len(population) == 0 {
+		return 0
+	}
+	distinct := map[string]bool{}
+	for _, o := range population {
+		hubs := append([]int{}, o.DNA.Hubs...)
+		sort.Ints(hubs)
+		distinct[fmt.Sprint(hubs)] = true
+	}
+	return float64(len(distinct)) / float64(len(population))
+}
+
 // Get the best organism
 func getBest(population []Organism) Organism {
 	best := 0.0
 	index := 0
 	for i := 0; i < len(population); i++ {
-		if population[i].Fitness < best {
+		if population[i].Fitness > best {
 			index = i
 			best = population[i].Fitness
 		}
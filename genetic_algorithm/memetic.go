@@ -0,0 +1,307 @@
+package main
+
+import (
+	"math/rand"
+)
+
+// Memetic configuration, the local search is applied to the offspring
+// produced by naturalSelection when memetic is set
+var memetic = false
+var localSearch = "tabu"
+var localSearchIterations = 10
+var localSearchCandidates = 10
+var localSearchFraction = 0.2
+var learning = "lamarckian"
+
+// iterations of the local tabu search run on its own as the local-ts baseline
+var tsIterations = 1000
+
+// calculate total_cost follow Spoke-Hub-Hub-spoke strategy
+func calcTotalCost(cost_matrix, flow_matrix [][]float64, alpha float64, solution []int) float64 {
+	var total_cost float64
+	total_cost = 0.0
+	for i, _ := range flow_matrix {
+		for j, _ := range flow_matrix {
+			collection_cost := flow_matrix[i][j] * cost_matrix[i][solution[i]]
+			transportation_cost := flow_matrix[i][j] * cost_matrix[solution[i]][solution[j]] * alpha
+			distribution_cost := flow_matrix[i][j] * cost_matrix[solution[j]][j]
+			cost := collection_cost + transportation_cost + distribution_cost
+			total_cost += cost
+		}
+	}
+	return total_cost
+}
+
+// cost difference of moving a single node to another hub, only the pairs
+// starting or ending at the node are affected
+func calcReallocationDelta(solution []int, node, hub int) float64 {
+	runTermination.Evaluated()
+	old_hub := solution[node]
+	delta := 0.0
+	for j, _ := range flow_matrix {
+		if j == node {
+			continue
+		}
+		out_flow := flow_matrix[node][j]
+		in_flow := flow_matrix[j][node]
+		delta += out_flow * (cost_matrix[node][hub] - cost_matrix[node][old_hub])
+		delta += out_flow * alpha * (cost_matrix[hub][solution[j]] - cost_matrix[old_hub][solution[j]])
+		delta += in_flow * (cost_matrix[hub][node] - cost_matrix[old_hub][node])
+		delta += in_flow * alpha * (cost_matrix[solution[j]][hub] - cost_matrix[solution[j]][old_hub])
+	}
+	self_flow := flow_matrix[node][node]
+	delta += self_flow * (cost_matrix[node][hub] + cost_matrix[hub][node] - cost_matrix[node][old_hub] - cost_matrix[old_hub][node])
+	return delta
+}
+
+func (c SolutionDNA) clone() *SolutionDNA {
+	dna := &SolutionDNA{Cost: c.Cost}
+	dna.Hubs = make([]int, len(c.Hubs))
+	dna.Solution = make([]int, len(c.Solution))
+	copy(dna.Hubs, c.Hubs)
+	copy(dna.Solution, c.Solution)
+	dna.Links = cloneLinks(c.Links)
+	dna.Arcs = cloneArcs(c.Arcs)
+	return dna
+}
+
+// a move of the local search, either a spoke reallocated to another hub or
+// a move evaluated in full (Swap) whose Result is the neighbour: a hub
+// replaced by one of the nodes allocated to it or a change of hub links
+type localMove struct {
+	Node   int
+	Hub    int
+	Swap   bool
+	Delta  float64
+	Result *SolutionDNA
+}
+
+// replace the hub of node by node itself, the hub's spokes follow unless
+// they are forbidden the new hub
+func swapHub(dna *SolutionDNA, node int) *SolutionDNA {
+	neighbor := dna.clone()
+	hub_to_switch := neighbor.Solution[node]
+	for i, hub := range neighbor.Solution {
+		if hub == hub_to_switch {
+			neighbor.Solution[i] = node
+		}
+	}
+	for i, hub := range neighbor.Hubs {
+		if hub == hub_to_switch {
+			neighbor.Hubs[i] = node
+		}
+	}
+	if neighbor.Links != nil {
+		relinkHub(neighbor.Links, hub_to_switch, node)
+	}
+	if neighbor.Arcs != nil {
+		relinkArcs(neighbor.Arcs, hub_to_switch, node)
+	}
+	repairAllocation(neighbor.Solution, neighbor.Hubs)
+	// the new hub has another capacity, the spokes are allocated again
+	if capacitated() {
+		neighbor.Solution = allocateCapacitated(neighbor.Hubs)
+	}
+	neighbor.Cost = normalize(calcSolutionCost(neighbor.Solution, neighbor.Hubs, neighbor.Links, neighbor.Arcs))
+	return neighbor
+}
+
+func randomSpoke(rng *rand.Rand, dna *SolutionDNA) int {
+	node := rng.Intn(len(dna.Solution))
+	for isInSlice(node, dna.Hubs) {
+		node = rng.Intn(len(dna.Solution))
+	}
+	return node
+}
+
+func randomMove(rng *rand.Rand, dna *SolutionDNA) localMove {
+	node := randomSpoke(rng, dna)
+	// reallocations do not change a multiple allocation solution, a spoke
+	// that may not replace its hub leaves it as it is
+	if (allocation == "multiple" || rng.Intn(2) == 0) && canSwapHub(node, dna.Solution[node]) {
+		neighbor := swapHub(dna, node)
+		// a swap past the max changes leaves the hubs as they are
+		if !withinChanges(neighbor.Hubs) {
+			return localMove{Node: node, Swap: true, Result: dna.clone()}
+		}
+		return localMove{Node: node, Swap: true, Delta: neighbor.Cost - dna.Cost, Result: neighbor}
+	}
+	if allocation == "multiple" {
+		return localMove{Node: node, Swap: true, Result: dna.clone()}
+	}
+	// an incomplete hub network moves a hub arc as often as a spoke
+	if incompleteNetwork() && rng.Intn(2) == 0 {
+		neighbor := dna.clone()
+		neighbor.Arcs, node = moveArc(neighbor.Arcs, neighbor.Hubs, rng.Intn)
+		neighbor.Cost = normalize(calcSolutionCost(neighbor.Solution, neighbor.Hubs, neighbor.Links, neighbor.Arcs))
+		return localMove{Node: node, Swap: true, Delta: neighbor.Cost - dna.Cost, Result: neighbor}
+	}
+	// under r-allocation the spoke's hub links change instead
+	if allocation == "r" {
+		neighbor := dna.clone()
+		moveLink(neighbor.Links, neighbor.Hubs, node, rng.Intn)
+		neighbor.Solution[node] = neighbor.Links[node][0]
+		neighbor.Cost = normalize(calcSolutionCost(neighbor.Solution, neighbor.Hubs, neighbor.Links, neighbor.Arcs))
+		return localMove{Node: node, Swap: true, Delta: neighbor.Cost - dna.Cost, Result: neighbor}
+	}
+	var others []int
+	for _, hub := range allowedHubs(node, dna.Hubs) {
+		if hub != dna.Solution[node] {
+			others = append(others, hub)
+		}
+	}
+	if len(others) == 0 {
+		return localMove{Node: node, Hub: dna.Solution[node]}
+	}
+	hub := others[rng.Intn(len(others))]
+	return localMove{Node: node, Hub: hub, Delta: reallocationDelta(dna, node, hub)}
+}
+
+// normalized cost difference of reallocating node to hub, evaluated in
+// full unless it is the plain total cost with a constant alpha over a
+// complete hub network and exact flows without forbidden allocations
+func reallocationDelta(dna *SolutionDNA, node, hub int) float64 {
+	if objective == "median" && !capacitated() && scaleModel == "constant" && !incompleteNetwork() && !robust() && len(forbiddenAllocations) == 0 {
+		return calcReallocationDelta(dna.Solution, node, hub) / total_flow
+	}
+	solution := make([]int, len(dna.Solution))
+	copy(solution, dna.Solution)
+	solution[node] = hub
+	return normalize(calcSolutionCost(solution, dna.Hubs, nil, dna.Arcs)) - dna.Cost
+}
+
+func (m localMove) apply(dna *SolutionDNA) *SolutionDNA {
+	if m.Swap {
+		return m.Result
+	}
+	neighbor := dna.clone()
+	neighbor.Solution[m.Node] = m.Hub
+	neighbor.Cost += m.Delta
+	return neighbor
+}
+
+// short tabu search over swap and reallocation moves, the moved node is
+// kept tabu for a few iterations unless the move improves the best
+func localTabuSearch(rng *rand.Rand, dna *SolutionDNA, iterations int, trace *Trace, termination *Termination) *SolutionDNA {
+	current := dna.clone()
+	best := current
+	best_iteration := 0
+
+	tabuSize := len(dna.Solution) / 5
+	tabuList := make([]int, 0, tabuSize+1)
+
+	for i := 0; i < iterations; i++ {
+		if termination.Stop(i-best_iteration, 100, best.Cost) {
+			break
+		}
+
+		var chosen *localMove
+		for j := 0; j < localSearchCandidates; j++ {
+			move := randomMove(rng, current)
+			aspirated := current.Cost+move.Delta < best.Cost
+			if isInSlice(move.Node, tabuList) && !aspirated {
+				continue
+			}
+			if chosen == nil || move.Delta < chosen.Delta {
+				chosen = &move
+			}
+		}
+		if chosen == nil {
+			continue
+		}
+
+		current = chosen.apply(current)
+		tabuList = append(tabuList, chosen.Node)
+		if len(tabuList) > tabuSize {
+			tabuList = tabuList[1:]
+		}
+		if current.Cost < best.Cost {
+			best = current
+			best_iteration = i
+		}
+		trace.Add(i, current.Cost, best.Cost, len(tabuList), 0)
+	}
+
+	// the incremental costs drift slightly, settle the exact value
+	best.Cost = normalize(calcSolutionCost(best.Solution, best.Hubs, best.Links, best.Arcs))
+	return best
+}
+
+// first improvement local search, stops after the given number of
+// improving moves or when a full pass finds no improvement
+func firstImprovement(rng *rand.Rand, dna *SolutionDNA, moves int) *SolutionDNA {
+	current := dna.clone()
+
+	for m := 0; m < moves; m++ {
+		improved := false
+		for _, node := range rng.Perm(len(current.Solution)) {
+			if isInSlice(node, current.Hubs) {
+				continue
+			}
+			for _, hub := range allowedHubs(node, current.Hubs) {
+				// the reallocation delta is only known for single allocation
+				if hub == current.Solution[node] || allocation != "single" {
+					continue
+				}
+				delta := reallocationDelta(current, node, hub)
+				if delta < -1e-9 {
+					current = localMove{Node: node, Hub: hub, Delta: delta}.apply(current)
+					improved = true
+					break
+				}
+			}
+			if improved {
+				break
+			}
+			if !canSwapHub(node, current.Solution[node]) {
+				continue
+			}
+			neighbor := swapHub(current, node)
+			if neighbor.Cost < current.Cost-1e-9 && withinChanges(neighbor.Hubs) {
+				current = neighbor
+				improved = true
+				break
+			}
+		}
+		if !improved {
+			break
+		}
+	}
+
+	current.Cost = normalize(calcSolutionCost(current.Solution, current.Hubs, current.Links, current.Arcs))
+	return current
+}
+
+// improve an offspring with the configured local search. Under lamarckian
+// learning the improved solution replaces the genotype, under baldwinian
+// learning only the fitness benefits and the genotype is passed on as is
+func (d *Organism) improve(rng *rand.Rand) {
+	var improved *SolutionDNA
+	if localSearch == "first" {
+		improved = firstImprovement(rng, d.DNA, localSearchIterations)
+	} else {
+		improved = localTabuSearch(rng, d.DNA, localSearchIterations, nil, nil)
+	}
+
+	if learning == "baldwinian" {
+		d.Learned = improved
+	} else {
+		d.DNA = improved
+	}
+	d.calcFitness()
+}
+
+// the local tabu search of the memetic algorithm on its own from a random
+// organism or the incumbent of a warm start, the pure local search baseline
+// the memetic algorithm is compared against. It is not the tabu search of
+// the ts binary.
+func RunTS() Organism {
+	rng := newRand()
+	organism := createOrganism(rng, cost_matrix, flow_matrix, alpha, no_hubs)
+	if warmStart() {
+		organism = incumbentOrganism(rng, 0)
+	}
+	organism.DNA = localTabuSearch(rng, organism.DNA, tsIterations, runTrace, runTermination)
+	organism.calcFitness()
+	return organism
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// shape of the network between the hubs: complete connects every pair of
+// hubs, arcs installs hubArcs hub arcs, tree a spanning tree of the hubs
+// and star every hub to one center hub. Flow between two hubs takes the
+// shortest path over the installed arcs, each arc discounted by alpha, and
+// the direct undiscounted leg when the hubs are not connected.
+var hubNetwork = "complete"
+var hubArcs = 0
+
+func checkHubNetwork() error {
+	switch hubNetwork {
+	case "complete", "tree", "star":
+		return nil
+	case "arcs":
+		if hubArcs < 1 {
+			return fmt.Errorf("the arcs network needs a positive number of hub arcs")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown hub network %q", hubNetwork)
+}
+
+func incompleteNetwork() bool {
+	return hubNetwork != "complete"
+}
+
+// the cheapest arcs of the configured shape between the hubs: the hubArcs
+// cheapest arcs, the minimum spanning tree or the cheapest star. Nil for a
+// complete network.
+func buildArcs(hubs []int) [][2]int {
+	switch hubNetwork {
+	case "arcs":
+		var arcs [][2]int
+		for a, k := range hubs {
+			for _, m := range hubs[a+1:] {
+				arcs = append(arcs, [2]int{k, m})
+			}
+		}
+		sort.SliceStable(arcs, func(a, b int) bool {
+			return arcCost(arcs[a]) < arcCost(arcs[b])
+		})
+		if len(arcs) > hubArcs {
+			arcs = arcs[:hubArcs]
+		}
+		return arcs
+	case "tree":
+		// Prim's algorithm from the first hub
+		arcs := [][2]int{}
+		in_tree := []int{hubs[0]}
+		for len(in_tree) < len(hubs) {
+			best := [2]int{-1, -1}
+			for _, k := range in_tree {
+				for _, m := range hubs {
+					if isInSlice(m, in_tree) {
+						continue
+					}
+					if best[0] == -1 || arcCost([2]int{k, m}) < arcCost(best) {
+						best = [2]int{k, m}
+					}
+				}
+			}
+			arcs = append(arcs, best)
+			in_tree = append(in_tree, best[1])
+		}
+		return arcs
+	case "star":
+		var best [][2]int
+		for _, center := range hubs {
+			arcs := starArcs(center, hubs)
+			if best == nil || calcArcsCost(arcs) < calcArcsCost(best) {
+				best = arcs
+			}
+		}
+		return best
+	}
+	return nil
+}
+
+func starArcs(center int, hubs []int) [][2]int {
+	arcs := [][2]int{}
+	for _, hub := range hubs {
+		if hub != center {
+			arcs = append(arcs, [2]int{center, hub})
+		}
+	}
+	return arcs
+}
+
+// cost of an arc in both directions
+func arcCost(a [2]int) float64 {
+	return cost_matrix[a[0]][a[1]] + cost_matrix[a[1]][a[0]]
+}
+
+func calcArcsCost(arcs [][2]int) float64 {
+	cost := 0.0
+	for _, a := range arcs {
+		cost += arcCost(a)
+	}
+	return cost
+}
+
+func isArc(a [2]int, arcs [][2]int) bool {
+	for _, b := range arcs {
+		if (a[0] == b[0] && a[1] == b[1]) || (a[0] == b[1] && a[1] == b[0]) {
+			return true
+		}
+	}
+	return false
+}
+
+func cloneArcs(arcs [][2]int) [][2]int {
+	if arcs == nil {
+		return nil
+	}
+	clone := make([][2]int, len(arcs))
+	copy(clone, arcs)
+	return clone
+}
+
+// the arcs after hub old_hub is moved to node new_hub, the shape of the
+// network is kept
+func relinkArcs(arcs [][2]int, old_hub, new_hub int) {
+	for k := range arcs {
+		for e := range arcs[k] {
+			if arcs[k][e] == old_hub {
+				arcs[k][e] = new_hub
+			}
+		}
+	}
+}
+
+// repairArcs keeps the inherited arcs between the hubs and restores the
+// shape of the network: the arcs network is filled up with the cheapest
+// arcs and cut to hubArcs arcs, a tree drops the arcs that close a cycle
+// and joins its parts with the cheapest arcs and a star keeps the center
+// of the most arcs. Nil for a complete network.
+func repairArcs(arcs [][2]int, hubs []int) [][2]int {
+	if !incompleteNetwork() {
+		return nil
+	}
+	kept := [][2]int{}
+	for _, a := range arcs {
+		if a[0] != a[1] && isInSlice(a[0], hubs) && isInSlice(a[1], hubs) && !isArc(a, kept) {
+			kept = append(kept, a)
+		}
+	}
+	var missing [][2]int
+	for a, k := range hubs {
+		for _, m := range hubs[a+1:] {
+			if k != m && !isArc([2]int{k, m}, kept) {
+				missing = append(missing, [2]int{k, m})
+			}
+		}
+	}
+	sort.SliceStable(missing, func(a, b int) bool {
+		return arcCost(missing[a]) < arcCost(missing[b])
+	})
+
+	switch hubNetwork {
+	case "arcs":
+		arcs = append(kept, missing...)
+		if len(arcs) > hubArcs {
+			arcs = arcs[:hubArcs]
+		}
+		return arcs
+	case "tree":
+		// Kruskal's algorithm over the inherited arcs first
+		tree := [][2]int{}
+		for _, a := range append(kept, missing...) {
+			if !reachable(a[0], tree)[a[1]] {
+				tree = append(tree, a)
+			}
+		}
+		return tree
+	case "star":
+		center, most := hubs[0], -1
+		for _, hub := range hubs {
+			n := 0
+			for _, a := range kept {
+				if a[0] == hub || a[1] == hub {
+					n++
+				}
+			}
+			if n > most {
+				center, most = hub, n
+			}
+		}
+		return starArcs(center, hubs)
+	}
+	return nil
+}
+
+// drop a random hub arc and add another one keeping the shape of the
+// network: any other arc, an arc joining the two parts of the tree again
+// or, for a star, all arcs of another center. Returns the new arcs and a
+// hub of the added arc. intn draws the random numbers.
+func moveArc(arcs [][2]int, hubs []int, intn func(n int) int) ([][2]int, int) {
+	if len(arcs) == 0 {
+		return arcs, hubs[intn(len(hubs))]
+	}
+	if hubNetwork == "star" {
+		center := hubs[intn(len(hubs))]
+		return starArcs(center, hubs), center
+	}
+
+	k := intn(len(arcs))
+	dropped := arcs[k]
+	kept := append(cloneArcs(arcs[:k]), arcs[k+1:]...)
+
+	// a tree must stay connected, the added arc joins the part of the
+	// dropped arc's first hub to the rest
+	part := map[int]bool{}
+	if hubNetwork == "tree" {
+		part = reachable(dropped[0], kept)
+	}
+
+	var added [][2]int
+	for a, i := range hubs {
+		for _, j := range hubs[a+1:] {
+			arc := [2]int{i, j}
+			if isArc(arc, kept) || isArc(arc, [][2]int{dropped}) {
+				continue
+			}
+			if hubNetwork == "tree" && part[i] == part[j] {
+				continue
+			}
+			added = append(added, arc)
+		}
+	}
+	if len(added) == 0 {
+		return arcs, dropped[intn(2)]
+	}
+	arc := added[intn(len(added))]
+	return append(kept, arc), arc[intn(2)]
+}
+
+// the hubs reached from a hub over the arcs
+func reachable(from int, arcs [][2]int) map[int]bool {
+	seen := map[int]bool{from: true}
+	queue := []int{from}
+	for len(queue) > 0 {
+		k := queue[0]
+		queue = queue[1:]
+		for _, a := range arcs {
+			for e, hub := range a {
+				if hub == k && !seen[a[1-e]] {
+					seen[a[1-e]] = true
+					queue = append(queue, a[1-e])
+				}
+			}
+		}
+	}
+	return seen
+}
+
+// shortest hub to hub costs over the installed arcs, by Floyd-Warshall
+// over the hubs. Hubs that are not connected use the undiscounted direct
+// leg.
+func calcHubDistances(hubs []int, arcs [][2]int) [][]float64 {
+	n := len(cost_matrix)
+	dist := make([][]float64, n)
+	for _, k := range hubs {
+		dist[k] = make([]float64, n)
+		for _, m := range hubs {
+			if k != m {
+				dist[k][m] = math.Inf(1)
+			}
+		}
+	}
+	for _, a := range arcs {
+		dist[a[0]][a[1]] = math.Min(dist[a[0]][a[1]], alpha*cost_matrix[a[0]][a[1]])
+		dist[a[1]][a[0]] = math.Min(dist[a[1]][a[0]], alpha*cost_matrix[a[1]][a[0]])
+	}
+	for _, via := range hubs {
+		for _, k := range hubs {
+			for _, m := range hubs {
+				if c := dist[k][via] + dist[via][m]; c < dist[k][m] {
+					dist[k][m] = c
+				}
+			}
+		}
+	}
+	for _, k := range hubs {
+		for _, m := range hubs {
+			if math.IsInf(dist[k][m], 1) {
+				dist[k][m] = cost_matrix[k][m]
+			}
+		}
+	}
+	return dist
+}
+
+// total cost of a single allocation over an incomplete hub network
+func calcNetworkCost(solution, hubs []int, arcs [][2]int) float64 {
+	dist := calcHubDistances(hubs, arcs)
+	total_cost := 0.0
+	for i := range flow_matrix {
+		for j := range flow_matrix {
+			if flow_matrix[i][j] == 0 {
+				continue
+			}
+			total_cost += flow_matrix[i][j] * (cost_matrix[i][solution[i]] + dist[solution[i]][solution[j]] + cost_matrix[solution[j]][j])
+		}
+	}
+	return total_cost
+}
+
+// printArcs reports the installed hub arcs and whether they connect all
+// hubs
+func printArcs(hubs []int, arcs [][2]int) {
+	labels := make([]string, len(arcs))
+	for k, a := range arcs {
+		labels[k] = strconv.Itoa(a[0]+1) + "-" + strconv.Itoa(a[1]+1)
+	}
+	fmt.Printf("%-40s\tHub Arcs: %s\tConnected[%t]\n", "", strings.Join(labels, " "), len(reachable(hubs[0], arcs)) == len(hubs))
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// NSGA-II trades the TNC against the number of hubs and the largest route
+// cost, the number of hubs is free while it runs
+var multiObjective = false
+var paretoCSV = ""
+var paretoJSON = ""
+
+// non-dominated solutions of the runs of the current configuration
+var currentFront = []Individual{}
+
+// Individual is an organism ranked by its objectives: TNC, number of hubs
+// and largest route cost, all minimised
+type Individual struct {
+	Organism   Organism
+	Objectives [3]float64
+	Rank       int
+	Crowding   float64
+}
+
+func evaluate(o Organism) Individual {
+	dna := o.DNA
+	return Individual{
+		Organism: o,
+		Objectives: [3]float64{
+			(calcMedianCost(dna.Solution, dna.Hubs, dna.Links, dna.Arcs) + calcAllocationPenalty(dna.Solution, dna.Hubs, dna.Links)) / total_flow,
+			float64(len(dna.Hubs)),
+			calcCenterCost(dna.Solution, dna.Hubs, dna.Links),
+		},
+	}
+}
+
+func dominates(a, b Individual) bool {
+	better := false
+	for m := range a.Objectives {
+		if a.Objectives[m] > b.Objectives[m] {
+			return false
+		}
+		if a.Objectives[m] < b.Objectives[m] {
+			better = true
+		}
+	}
+	return better
+}
+
+// fast non-dominated sort, sets the rank of every individual and returns
+// the fronts as indices
+func nonDominatedSort(population []Individual) [][]int {
+	dominated := make([][]int, len(population))
+	counts := make([]int, len(population))
+	fronts := [][]int{{}}
+	for p := range population {
+		for q := range population {
+			if dominates(population[p], population[q]) {
+				dominated[p] = append(dominated[p], q)
+			} else if dominates(population[q], population[p]) {
+				counts[p]++
+			}
+		}
+		if counts[p] == 0 {
+			population[p].Rank = 0
+			fronts[0] = append(fronts[0], p)
+		}
+	}
+	for f := 0; len(fronts[f]) > 0; f++ {
+		next := []int{}
+		for _, p := range fronts[f] {
+			for _, q := range dominated[p] {
+				counts[q]--
+				if counts[q] == 0 {
+					population[q].Rank = f + 1
+					next = append(next, q)
+				}
+			}
+		}
+		fronts = append(fronts, next)
+	}
+	return fronts[:len(fronts)-1]
+}
+
+// crowding distance of the members of one front, the boundary solutions
+// of every objective are always kept
+func crowdingDistance(population []Individual, front []int) {
+	for _, p := range front {
+		population[p].Crowding = 0
+	}
+	for m := 0; m < 3; m++ {
+		sorted := make([]int, len(front))
+		copy(sorted, front)
+		sort.SliceStable(sorted, func(a, b int) bool {
+			return population[sorted[a]].Objectives[m] < population[sorted[b]].Objectives[m]
+		})
+		low := population[sorted[0]].Objectives[m]
+		high := population[sorted[len(sorted)-1]].Objectives[m]
+		population[sorted[0]].Crowding = math.Inf(1)
+		population[sorted[len(sorted)-1]].Crowding = math.Inf(1)
+		if high == low {
+			continue
+		}
+		for k := 1; k < len(sorted)-1; k++ {
+			gap := population[sorted[k+1]].Objectives[m] - population[sorted[k-1]].Objectives[m]
+			population[sorted[k]].Crowding += gap / (high - low)
+		}
+	}
+}
+
+// binary tournament on rank, then crowding distance
+func crowdedTournament(rng *rand.Rand, population []Individual) Organism {
+	a := population[rng.Intn(len(population))]
+	b := population[rng.Intn(len(population))]
+	if a.Rank < b.Rank || (a.Rank == b.Rank && a.Crowding > b.Crowding) {
+		return a.Organism
+	}
+	return b.Organism
+}
+
+// the best size individuals by front and crowding distance
+func survivors(population []Individual, size int) []Individual {
+	next := make([]Individual, 0, size)
+	for _, front := range nonDominatedSort(population) {
+		crowdingDistance(population, front)
+		if len(next)+len(front) <= size {
+			for _, p := range front {
+				next = append(next, population[p])
+			}
+			continue
+		}
+		sort.SliceStable(front, func(a, b int) bool {
+			return population[front[a]].Crowding > population[front[b]].Crowding
+		})
+		for _, p := range front[:size-len(next)] {
+			next = append(next, population[p])
+		}
+		break
+	}
+	return next
+}
+
+// paretoFilter keeps the non-dominated individuals, one per objective vector
+func paretoFilter(population []Individual) []Individual {
+	front := []Individual{}
+	seen := map[[3]float64]bool{}
+	for _, p := range population {
+		dominated := false
+		for _, q := range population {
+			if dominates(q, p) {
+				dominated = true
+				break
+			}
+		}
+		if !dominated && !seen[p.Objectives] {
+			seen[p.Objectives] = true
+			front = append(front, p)
+		}
+	}
+	sort.SliceStable(front, func(a, b int) bool {
+		return front[a].Objectives[0] < front[b].Objectives[0]
+	})
+	return front
+}
+
+// RunNSGA evolves the population with NSGA-II, the offspring are bred
+// with the crossover and mutation of the GA. The Pareto front is added to
+// currentFront and its solution of the lowest TNC is returned.
+func RunNSGA() Organism {
+	rng := newRand()
+	settings := defaultSettings()
+
+	population := make([]Individual, PopSize)
+	for i := range population {
+		population[i] = evaluate(createOrganism(rng, cost_matrix, flow_matrix, alpha, no_hubs))
+	}
+	if warmStart() {
+		population[0] = evaluate(incumbentOrganism(rng, 0))
+	}
+	population = survivors(population, PopSize)
+
+	// the fittest organism so far and its generation, for the trace, the
+	// target cost and the stall limit
+	var fittest Organism
+	improved := 0
+	for g := 0; g < generations; g++ {
+		offspring := make([]Individual, PopSize)
+		for i := range offspring {
+			a := crowdedTournament(rng, population)
+			b := crowdedTournament(rng, population)
+			child := crossover(rng, a, b)
+			child.mutate(rng, settings.MutationRate)
+			if !child.isValid() {
+				child = createOrganism(rng, cost_matrix, flow_matrix, alpha, no_hubs)
+			}
+			child.calcFitness()
+			child.Generation = g
+			offspring[i] = evaluate(child)
+		}
+		population = survivors(append(population, offspring...), PopSize)
+
+		organisms := make([]Organism, len(population))
+		for k, individual := range population {
+			organisms[k] = individual.Organism
+		}
+		current := getBest(organisms)
+		if current.Fitness > fittest.Fitness {
+			fittest, improved = current, g
+		}
+		runTrace.Add(g, 1/current.Fitness, 1/fittest.Fitness, 0, populationDiversity(organisms))
+		// the front keeps spreading while the fittest stalls, NSGA-II only
+		// stalls on the stall limit
+		if runTermination.Stop(g-improved, -1, 1/fittest.Fitness) {
+			break
+		}
+	}
+
+	front := paretoFilter(population)
+	currentFront = append(currentFront, front...)
+
+	best := front[0].Organism
+	best.calcFitness()
+	return best
+}
+
+// hypervolume of a front of three minimised objectives dominated up to the
+// reference point, by slicing along the last objective
+func hypervolume(points [][3]float64, reference [3]float64) float64 {
+	sorted := [][3]float64{}
+	for _, p := range points {
+		if p[0] < reference[0] && p[1] < reference[1] && p[2] < reference[2] {
+			sorted = append(sorted, p)
+		}
+	}
+	sort.SliceStable(sorted, func(a, b int) bool { return sorted[a][2] < sorted[b][2] })
+
+	volume := 0.0
+	for k := range sorted {
+		top := reference[2]
+		if k+1 < len(sorted) {
+			top = sorted[k+1][2]
+		}
+		volume += hypervolume2D(sorted[:k+1], reference) * (top - sorted[k][2])
+	}
+	return volume
+}
+
+// area dominated by the points in the first two objectives
+func hypervolume2D(points [][3]float64, reference [3]float64) float64 {
+	sorted := make([][3]float64, len(points))
+	copy(sorted, points)
+	sort.SliceStable(sorted, func(a, b int) bool { return sorted[a][0] < sorted[b][0] })
+
+	area := 0.0
+	y := reference[1]
+	for _, p := range sorted {
+		if p[1] < y {
+			area += (reference[0] - p[0]) * (y - p[1])
+			y = p[1]
+		}
+	}
+	return area
+}
+
+// ParetoResult is the front of one configuration. The objectives are
+// divided by the reference point, 10% beyond the worst TNC and route cost
+// of the front and one hub more than its largest hub count, so the
+// hypervolume lies between 0 and 1.
+type ParetoResult struct {
+	Dataset     string           `json:"dataset"`
+	Alpha       float64          `json:"alpha"`
+	Reference   [3]float64       `json:"reference"`
+	Hypervolume float64          `json:"hypervolume"`
+	Front       []ParetoSolution `json:"front"`
+}
+
+type ParetoSolution struct {
+	Hubs         []int   `json:"hub_locations"`
+	NoHubs       int     `json:"no_hubs"`
+	TNC          float64 `json:"tnc"`
+	MaxRouteCost float64 `json:"max_route_cost"`
+	Solution     []int   `json:"solution"`
+}
+
+var paretoResults = []ParetoResult{}
+
+// paretoResult merges the fronts of the runs of a configuration
+func paretoResult(dataset string, front []Individual) ParetoResult {
+	front = paretoFilter(front)
+	result := ParetoResult{Dataset: dataset, Alpha: alpha}
+	for _, p := range front {
+		for m := range p.Objectives {
+			result.Reference[m] = math.Max(result.Reference[m], p.Objectives[m])
+		}
+	}
+	result.Reference[0] *= 1.1
+	result.Reference[1] += 1
+	result.Reference[2] *= 1.1
+
+	points := make([][3]float64, len(front))
+	for k, p := range front {
+		for m := range p.Objectives {
+			points[k][m] = p.Objectives[m] / result.Reference[m]
+		}
+		// the exported nodes are numbered from 1
+		dna := p.Organism.DNA
+		solution := ParetoSolution{
+			NoHubs:       len(dna.Hubs),
+			TNC:          p.Objectives[0],
+			MaxRouteCost: p.Objectives[2],
+		}
+		for _, hub := range dna.Hubs {
+			solution.Hubs = append(solution.Hubs, hub+1)
+		}
+		for _, hub := range dna.Solution {
+			solution.Solution = append(solution.Solution, hub+1)
+		}
+		result.Front = append(result.Front, solution)
+	}
+	result.Hypervolume = hypervolume(points, [3]float64{1, 1, 1})
+	return result
+}
+
+func writeParetoCSV(location string, results []ParetoResult) error {
+	f, err := os.Create(location)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"dataset", "alpha", "no_hubs", "tnc", "max_route_cost", "hub_locations", "hypervolume"})
+	for _, r := range results {
+		for _, s := range r.Front {
+			hubs := make([]string, len(s.Hubs))
+			for k, hub := range s.Hubs {
+				hubs[k] = strconv.Itoa(hub)
+			}
+			w.Write([]string{
+				r.Dataset,
+				fmt.Sprintf("%f", r.Alpha),
+				strconv.Itoa(s.NoHubs),
+				fmt.Sprintf("%f", s.TNC),
+				fmt.Sprintf("%f", s.MaxRouteCost),
+				strings.Join(hubs, " "),
+				fmt.Sprintf("%f", r.Hypervolume),
+			})
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func writeParetoJSON(location string, results []ParetoResult) error {
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(location, data, 0644)
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// warm start from an existing solution, a text file with the hubs and the
+// hub of every node numbered from 1 as Candidate.Print writes them:
+//
+//	Hubs: 2 6 8
+//	Solution: 2 2 6 6 6 6 8 8 8 2
+//
+// The Hubs line may be left out, the hubs are then the nodes allocated to
+// themselves. maxChanges limits how many hubs may differ from the
+// incumbent, -1 for no limit.
+var initialFile = ""
+var maxChanges = -1
+
+var incumbentHubs = []int{}
+var incumbentSolution = []int{}
+
+func warmStart() bool {
+	return len(incumbentSolution) > 0
+}
+
+// readSolutionFile reads the hubs and the allocation of a solution file,
+// without a Hubs line the hubs are the nodes allocated to themselves
+func readSolutionFile(location string) (hubs, solution []int, err error) {
+	f, err := os.Open(location)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	labels := func(line string) ([]int, error) {
+		fields := strings.FieldsFunc(line, func(r rune) bool {
+			return r == ' ' || r == '\t' || r == ',' || r == '[' || r == ']'
+		})
+		nodes := make([]int, len(fields))
+		for k, field := range fields {
+			node, err := strconv.Atoi(field)
+			if err != nil {
+				return nil, err
+			}
+			if node < 1 {
+				return nil, fmt.Errorf("nodes are numbered from 1, got %d", node)
+			}
+			nodes[k] = node - 1
+		}
+		return nodes, nil
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), ":")
+		if !found {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "Hubs":
+			hubs, err = labels(value)
+		case "Solution":
+			solution, err = labels(value)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %s", location, err.Error())
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if len(solution) == 0 {
+		return nil, nil, fmt.Errorf("%s has no Solution line", location)
+	}
+	if len(hubs) == 0 {
+		for i, hub := range solution {
+			if i == hub {
+				hubs = append(hubs, i)
+			}
+		}
+	}
+	return hubs, solution, nil
+}
+
+// loadIncumbent reads the solution file of the warm start
+func loadIncumbent() (err error) {
+	incumbentHubs, incumbentSolution, err = readSolutionFile(initialFile)
+	if err != nil {
+		return err
+	}
+	for i, hub := range incumbentSolution {
+		if hub >= len(incumbentSolution) {
+			return fmt.Errorf("node %d is allocated to node %d beyond the %d nodes", i+1, hub+1, len(incumbentSolution))
+		}
+		if !isInSlice(hub, incumbentHubs) {
+			return fmt.Errorf("node %d is allocated to node %d which is not a hub", i+1, hub+1)
+		}
+	}
+	for _, hub := range incumbentHubs {
+		if hub >= len(incumbentSolution) {
+			return fmt.Errorf("hub %d is beyond the %d nodes", hub+1, len(incumbentSolution))
+		}
+		if !canBeHub(hub) {
+			return fmt.Errorf("the initial solution opens the forbidden hub %d", hub+1)
+		}
+	}
+	for _, node := range forcedHubs {
+		if !isInSlice(node, incumbentHubs) {
+			return fmt.Errorf("the initial solution misses the forced hub %d", node+1)
+		}
+	}
+	if maxChanges < -1 {
+		return fmt.Errorf("max changes must be -1 or more, got %d", maxChanges)
+	}
+	return nil
+}
+
+// copies of the incumbent hubs and allocation
+func incumbent() ([]int, []int) {
+	return append([]int{}, incumbentHubs...), append([]int{}, incumbentSolution...)
+}
+
+// the number of hubs that differ from the incumbent, the larger of the
+// hubs opened and the hubs closed so moving a hub counts once
+func hubChanges(hubs []int) int {
+	opened, closed := 0, 0
+	for _, hub := range hubs {
+		if !isInSlice(hub, incumbentHubs) {
+			opened++
+		}
+	}
+	for _, hub := range incumbentHubs {
+		if !isInSlice(hub, hubs) {
+			closed++
+		}
+	}
+	if opened > closed {
+		return opened
+	}
+	return closed
+}
+
+// whether no more than maxChanges hubs differ from the incumbent
+func withinChanges(hubs []int) bool {
+	return !warmStart() || maxChanges < 0 || hubChanges(hubs) <= maxChanges
+}
+
+// limitChanges moves changed hubs back to the incumbent until no more than
+// maxChanges differ from it: an opened hub gives way to a closed one and a
+// free number of hubs drops the opened or reopens the closed hubs left over
+func limitChanges(hubs []int, intn func(n int) int) []int {
+	limited := append([]int{}, hubs...)
+	for !withinChanges(limited) {
+		var opened, closed []int
+		for k, hub := range limited {
+			if !isInSlice(hub, incumbentHubs) {
+				opened = append(opened, k)
+			}
+		}
+		for _, hub := range incumbentHubs {
+			if !isInSlice(hub, limited) {
+				closed = append(closed, hub)
+			}
+		}
+		switch {
+		case len(opened) > 0 && len(closed) > 0:
+			limited[opened[intn(len(opened))]] = closed[intn(len(closed))]
+		case len(opened) > 0 && freeHubs():
+			k := opened[intn(len(opened))]
+			limited = append(limited[:k], limited[k+1:]...)
+		case len(closed) > 0 && freeHubs():
+			limited = append(limited, closed[intn(len(closed))])
+		default:
+			// a fixed number of hubs other than the incumbent's comes no closer
+			return limited
+		}
+	}
+	return limited
+}
+
+// every change over maxChanges costs three times the largest cost, what
+// any route costs at most, scaled by the flow under the median objective
+func calcChangePenalty(hubs []int) float64 {
+	if !warmStart() || maxChanges < 0 {
+		return 0
+	}
+	excess := hubChanges(hubs) - maxChanges
+	if excess <= 0 {
+		return 0
+	}
+	penalty := float64(excess) * 3 * maxCost()
+	if objective == "median" {
+		penalty *= total_flow
+	}
+	return penalty
+}
+
+// printWarmStart reports the incumbent in the configuration header
+func printWarmStart() {
+	labels := make([]string, len(incumbentHubs))
+	for k, hub := range incumbentHubs {
+		labels[k] = strconv.Itoa(hub + 1)
+	}
+	fmt.Printf("Warm Start: File[%s]\tNodes[%d]\tHubs[%s]\tMax Changes[%d]\n", initialFile, len(incumbentSolution), strings.Join(labels, " "), maxChanges)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// variable names use 1-based node labels, as Candidate.Print does
+func allocationName(i, k int) string {
+	return fmt.Sprintf("z_%d_%d", i+1, k+1)
+}
+
+// z_ik = 1 when node i is allocated to hub k, z_kk = 1 when k is a hub.
+// Both formulations share these variables and the constraints
+//
+//	sum_k z_kk = p
+//	sum_k z_ik = 1     for every i
+//	z_ik <= z_kk       for every i != k
+//
+// and the hub constraints fix z_kk = 1 for a forced hub k, z_kk = 0 for a
+// forbidden hub k and z_ik = 0 for a forbidden allocation of i to k.
+func addAllocation(m *Model, number_of_hubs int, objective func(i, k int) float64) {
+	n := len(cost_matrix)
+	for i := 0; i < n; i++ {
+		for k := 0; k < n; k++ {
+			m.addVariable(allocationName(i, k), true, objective(i, k))
+		}
+	}
+
+	hubs := m.addConstraint("hubs", "=", float64(number_of_hubs))
+	for k := 0; k < n; k++ {
+		hubs.add(m.variable(allocationName(k, k)), 1)
+	}
+	for i := 0; i < n; i++ {
+		assign := m.addConstraint(fmt.Sprintf("assign_%d", i+1), "=", 1)
+		for k := 0; k < n; k++ {
+			assign.add(m.variable(allocationName(i, k)), 1)
+		}
+	}
+	for i := 0; i < n; i++ {
+		for k := 0; k < n; k++ {
+			if i == k {
+				continue
+			}
+			link := m.addConstraint(fmt.Sprintf("open_%d_%d", i+1, k+1), "<=", 0)
+			link.add(m.variable(allocationName(i, k)), 1)
+			link.add(m.variable(allocationName(k, k)), -1)
+		}
+	}
+
+	for _, k := range forcedHubs {
+		m.addConstraint(fmt.Sprintf("forced_%d", k+1), "=", 1).add(m.variable(allocationName(k, k)), 1)
+	}
+	for _, k := range forbiddenHubs {
+		m.addConstraint(fmt.Sprintf("forbidden_%d", k+1), "=", 0).add(m.variable(allocationName(k, k)), 1)
+	}
+	// sorted so the model is written the same every time
+	pairs := [][2]int{}
+	for pair := range forbiddenAllocations {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(a, b int) bool {
+		return pairs[a][0] < pairs[b][0] || (pairs[a][0] == pairs[b][0] && pairs[a][1] < pairs[b][1])
+	})
+	for _, pair := range pairs {
+		m.addConstraint(fmt.Sprintf("forbidden_%d_%d", pair[0]+1, pair[1]+1), "=", 0).add(m.variable(allocationName(pair[0], pair[1])), 1)
+	}
+}
+
+// Ernst and Krishnamoorthy's flow based formulation, y_ikl is the flow
+// originating at i that travels from hub k to hub l. The spoke i sends
+// its outflow O_i to its hub k and receives its inflow D_i from it, so
+// asymmetric costs price the two legs apart.
+//
+//	min sum_ik (C_ik O_i + C_ki D_i) z_ik + sum_ikl alpha C_kl y_ikl
+//	sum_l y_ikl - sum_l y_ilk = O_i z_ik - sum_j W_ij z_jk   for every i, k
+func ernstKrishnamoorthy(number_of_hubs int) *Model {
+	n := len(cost_matrix)
+	m := NewModel(fmt.Sprintf("USApHMP_EK_n%d_p%d_alpha%g", n, number_of_hubs, alpha))
+
+	outflow := make([]float64, n)
+	inflow := make([]float64, n)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			outflow[i] += flow_matrix[i][j]
+			inflow[j] += flow_matrix[i][j]
+		}
+	}
+
+	addAllocation(m, number_of_hubs, func(i, k int) float64 {
+		return cost_matrix[i][k]*outflow[i] + cost_matrix[k][i]*inflow[i]
+	})
+
+	flowName := func(i, k, l int) string {
+		return fmt.Sprintf("y_%d_%d_%d", i+1, k+1, l+1)
+	}
+	for i := 0; i < n; i++ {
+		for k := 0; k < n; k++ {
+			for l := 0; l < n; l++ {
+				if k != l {
+					m.addVariable(flowName(i, k, l), false, alpha*cost_matrix[k][l])
+				}
+			}
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		for k := 0; k < n; k++ {
+			balance := m.addConstraint(fmt.Sprintf("balance_%d_%d", i+1, k+1), "=", 0)
+			for l := 0; l < n; l++ {
+				if l == k {
+					continue
+				}
+				balance.add(m.variable(flowName(i, k, l)), 1)
+				balance.add(m.variable(flowName(i, l, k)), -1)
+			}
+			balance.add(m.variable(allocationName(i, k)), -outflow[i])
+			for j := 0; j < n; j++ {
+				if flow_matrix[i][j] != 0 {
+					balance.add(m.variable(allocationName(j, k)), flow_matrix[i][j])
+				}
+			}
+		}
+	}
+	return m
+}
+
+// O'Kelly's quadratic model linearised with path variables, x_ijkm is the
+// fraction of the flow from i to j routed through hubs k and m
+//
+//	min sum_ijkm W_ij (C_ik + alpha C_km + C_mj) x_ijkm
+//	sum_m x_ijkm = z_ik   for every i, j, k
+//	sum_k x_ijkm = z_jm   for every i, j, m
+//
+// Pairs without flow need no path. The model has n^4 path variables and
+// is only practical for the smaller instances.
+func oKelly(number_of_hubs int) *Model {
+	n := len(cost_matrix)
+	m := NewModel(fmt.Sprintf("USApHMP_OKelly_n%d_p%d_alpha%g", n, number_of_hubs, alpha))
+
+	addAllocation(m, number_of_hubs, func(i, k int) float64 { return 0 })
+
+	pathName := func(i, j, k, l int) string {
+		return fmt.Sprintf("x_%d_%d_%d_%d", i+1, j+1, k+1, l+1)
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if flow_matrix[i][j] == 0 {
+				continue
+			}
+			for k := 0; k < n; k++ {
+				for l := 0; l < n; l++ {
+					cost := flow_matrix[i][j] * (cost_matrix[i][k] + alpha*cost_matrix[k][l] + cost_matrix[l][j])
+					m.addVariable(pathName(i, j, k, l), false, cost)
+				}
+			}
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if flow_matrix[i][j] == 0 {
+				continue
+			}
+			for k := 0; k < n; k++ {
+				first := m.addConstraint(fmt.Sprintf("first_%d_%d_%d", i+1, j+1, k+1), "=", 0)
+				for l := 0; l < n; l++ {
+					first.add(m.variable(pathName(i, j, k, l)), 1)
+				}
+				first.add(m.variable(allocationName(i, k)), -1)
+			}
+			for l := 0; l < n; l++ {
+				second := m.addConstraint(fmt.Sprintf("second_%d_%d_%d", i+1, j+1, l+1), "=", 0)
+				for k := 0; k < n; k++ {
+					second.add(m.variable(pathName(i, j, k, l)), 1)
+				}
+				second.add(m.variable(allocationName(j, l)), -1)
+			}
+		}
+	}
+	return m
+}
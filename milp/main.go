@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var cost_matrix = [][]float64{}
+var flow_matrix = [][]float64{}
+var total_flow float64
+
+var no_hubs = 3
+var alpha = 0.8
+
+// Configurations
+var costFile = "Cost_matrix10.csv"
+var flowFile = "Flow_matrix10.csv"
+var no_nodes = 10
+var formulation = "ek"
+var format = "lp"
+var output = ""
+var solutionFile = ""
+
+func read_matrix(location string, no_nodes int) (matrix [][]float64, err error) {
+	f, err := os.Open(location)
+	if err != nil {
+		return matrix, err
+	}
+	defer f.Close()
+
+	// Create a new reader.
+	r := csv.NewReader(bufio.NewReader(f))
+
+	row := []float64{}
+
+	for {
+		record, err := r.Read()
+
+		// Stop at EOF.
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return matrix, err
+		}
+		if len(matrix) == no_nodes || len(record) != no_nodes {
+			return matrix, fmt.Errorf("%s is not a %d x %d matrix, row %d has %d columns", location, no_nodes, no_nodes, len(matrix)+1, len(record))
+		}
+
+		for i := 0; i < no_nodes; i++ {
+			value, err := strconv.ParseFloat(record[i], 10)
+			if err != nil {
+				return matrix, err
+			} else {
+				row = append(row, value)
+			}
+		}
+		matrix = append(matrix, row)
+		row = []float64{}
+	}
+	if len(matrix) != no_nodes {
+		return matrix, fmt.Errorf("%s is not a %d x %d matrix, it has %d rows", location, no_nodes, no_nodes, len(matrix))
+	}
+
+	return matrix, nil
+}
+
+func calcTotalFlow(flow_matrix [][]float64) float64 {
+	total_flow := 0.0
+	for _, c := range flow_matrix {
+		for _, e := range c {
+			total_flow += e
+		}
+	}
+	return total_flow
+}
+
+// calculate total_cost follow Spoke-Hub-Hub-spoke strategy
+func calcTotalCost(cost_matrix, flow_matrix [][]float64, alpha float64, solution []int) float64 {
+	var total_cost float64
+	total_cost = 0.0
+	for i, _ := range flow_matrix {
+		for j, _ := range flow_matrix {
+			collection_cost := flow_matrix[i][j] * cost_matrix[i][solution[i]]
+			transportation_cost := flow_matrix[i][j] * cost_matrix[solution[i]][solution[j]] * alpha
+			distribution_cost := flow_matrix[i][j] * cost_matrix[solution[j]][j]
+			cost := collection_cost + transportation_cost + distribution_cost
+			total_cost += cost
+		}
+	}
+	return total_cost
+}
+
+func isInSlice(a int, list []int) bool {
+	for _, b := range list {
+		if b == a {
+			return true
+		}
+	}
+	return false
+}
+
+type Candidate struct {
+	Solution       []int
+	Cost           float64
+	Hubs           []int
+	NormalizedCost float64
+	ElapsedTime    time.Duration
+	Iteration      int
+}
+
+func (c Candidate) Print() {
+	hubs := make([]string, len(c.Hubs))
+	for k, hub := range c.Hubs {
+		hubs[k] = strconv.Itoa(hub + 1)
+	}
+	fmt.Printf("Hubs: %s\n", strings.Join(hubs, " "))
+	fmt.Printf("Nodes:  \t")
+	for i, _ := range c.Solution {
+		fmt.Printf("%-2d\t", i+1)
+	}
+	fmt.Printf("\n")
+	fmt.Printf("Solution:\t")
+	for _, n := range c.Solution {
+		fmt.Printf("%-2d\t", n+1)
+	}
+	fmt.Printf("\nTotal Cost: %+v\n", c.Cost)
+	fmt.Printf("Normalized Cost: %+v\n", c.NormalizedCost)
+}
+
+func (c *Candidate) calcCost(alpha float64) {
+	c.Cost = calcTotalCost(cost_matrix, flow_matrix, alpha, c.Solution)
+	c.NormalizedCost = c.Cost / total_flow
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n")
+	fmt.Fprintf(os.Stderr, "  milp export [flags]   write the MILP of an instance in LP or MPS format\n")
+	fmt.Fprintf(os.Stderr, "  milp import [flags]   evaluate the solution file written by a MILP solver\n")
+}
+
+func instanceFlags(fs *flag.FlagSet) {
+	fs.StringVar(&costFile, "cost", costFile, "cost matrix CSV")
+	fs.StringVar(&flowFile, "flow", flowFile, "flow matrix CSV")
+	fs.IntVar(&no_nodes, "nodes", no_nodes, "number of nodes of the instance")
+	fs.Float64Var(&alpha, "alpha", alpha, "discount factor of the hub to hub transport")
+}
+
+func readInstance() (err error) {
+	cost_matrix, err = read_matrix(costFile, no_nodes)
+	if err != nil {
+		return err
+	}
+	flow_matrix, err = read_matrix(flowFile, no_nodes)
+	if err != nil {
+		return err
+	}
+	total_flow = calcTotalFlow(flow_matrix)
+	return nil
+}
+
+func export(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	instanceFlags(fs)
+	fs.IntVar(&no_hubs, "hubs", no_hubs, "number of hubs")
+	fs.StringVar(&formulation, "model", formulation, "ek for the Ernst and Krishnamoorthy flow formulation, okelly for the linearised O'Kelly formulation")
+	fs.StringVar(&format, "format", format, "lp or mps")
+	fs.StringVar(&output, "output", output, "file the model is written to, standard output when empty")
+	fs.StringVar(&constraintsFile, "hub-constraints", constraintsFile, "CSV of forced,NODE, forbidden,NODE and forbidden_allocation,SPOKE,HUB rows, nodes numbered from 1")
+	fs.StringVar(&forcedOption, "forced-hubs", forcedOption, "comma separated nodes, numbered from 1, that are always hubs")
+	fs.StringVar(&forbiddenOption, "forbidden-hubs", forbiddenOption, "comma separated nodes, numbered from 1, that are never hubs")
+	fs.StringVar(&forbiddenAllocationOption, "forbidden-allocations", forbiddenAllocationOption, "comma separated SPOKE:HUB pairs, numbered from 1, of allocations that are not allowed")
+	fs.Parse(args)
+
+	if err := readInstance(); err != nil {
+		return err
+	}
+	if err := loadHubConstraints(); err != nil {
+		return err
+	}
+	if err := checkHubConstraints(no_nodes, no_hubs); err != nil {
+		return err
+	}
+
+	var m *Model
+	switch formulation {
+	case "ek":
+		m = ernstKrishnamoorthy(no_hubs)
+	case "okelly":
+		m = oKelly(no_hubs)
+	default:
+		return fmt.Errorf("unknown model %q", formulation)
+	}
+
+	w := os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	switch format {
+	case "lp":
+		return m.WriteLP(w)
+	case "mps":
+		return m.WriteMPS(w)
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+func importSolution(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	instanceFlags(fs)
+	fs.StringVar(&solutionFile, "solution", solutionFile, "solution file of the MILP solver")
+	fs.Parse(args)
+
+	if solutionFile == "" {
+		return fmt.Errorf("no solution file given")
+	}
+	if err := readInstance(); err != nil {
+		return err
+	}
+
+	solution, err := readSolution(solutionFile, no_nodes)
+	if err != nil {
+		return err
+	}
+	c := candidateFromSolution(solution)
+	c.Print()
+	return nil
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "export":
+		err = export(os.Args[2:])
+	case "import":
+		err = importSolution(os.Args[2:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
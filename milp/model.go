@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Term is a coefficient times a variable
+type Term struct {
+	Variable int
+	Coef     float64
+}
+
+// Constraint is a linear row with a sense of "=", "<=" or ">="
+type Constraint struct {
+	Name  string
+	Terms []Term
+	Sense string
+	RHS   float64
+	index map[int]int
+}
+
+// add merges the coefficient into the row, a variable appears once
+func (c *Constraint) add(variable int, coef float64) {
+	if c.index == nil {
+		c.index = map[int]int{}
+	}
+	if t, ok := c.index[variable]; ok {
+		c.Terms[t].Coef += coef
+		return
+	}
+	c.index[variable] = len(c.Terms)
+	c.Terms = append(c.Terms, Term{Variable: variable, Coef: coef})
+}
+
+// Variable of the model, continuous variables are non negative
+type Variable struct {
+	Name      string
+	Binary    bool
+	Objective float64
+}
+
+// Model is a minimisation MILP
+type Model struct {
+	Name        string
+	Variables   []Variable
+	Constraints []*Constraint
+	variables   map[string]int
+}
+
+func NewModel(name string) *Model {
+	return &Model{Name: name, variables: map[string]int{}}
+}
+
+func (m *Model) addVariable(name string, binary bool, objective float64) int {
+	m.variables[name] = len(m.Variables)
+	m.Variables = append(m.Variables, Variable{Name: name, Binary: binary, Objective: objective})
+	return len(m.Variables) - 1
+}
+
+func (m *Model) variable(name string) int {
+	return m.variables[name]
+}
+
+func (m *Model) addConstraint(name, sense string, rhs float64) *Constraint {
+	c := &Constraint{Name: name, Sense: sense, RHS: rhs}
+	m.Constraints = append(m.Constraints, c)
+	return c
+}
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// LP files are read line by line by some solvers, long expressions are
+// wrapped after a few terms
+const termsPerLine = 8
+
+func writeTerms(w *bufio.Writer, m *Model, terms []Term) {
+	for n, t := range terms {
+		if n > 0 && n%termsPerLine == 0 {
+			fmt.Fprintf(w, "\n   ")
+		}
+		sign := "+"
+		coef := t.Coef
+		if coef < 0 {
+			sign = "-"
+			coef = -coef
+		}
+		fmt.Fprintf(w, " %s %s %s", sign, formatNumber(coef), m.Variables[t.Variable].Name)
+	}
+}
+
+// WriteLP writes the model in CPLEX LP format
+func (m *Model) WriteLP(out io.Writer) error {
+	w := bufio.NewWriter(out)
+
+	fmt.Fprintf(w, "\\ %s\n", m.Name)
+	fmt.Fprintf(w, "Minimize\n obj:")
+	objective := []Term{}
+	for v, variable := range m.Variables {
+		if variable.Objective != 0 {
+			objective = append(objective, Term{Variable: v, Coef: variable.Objective})
+		}
+	}
+	writeTerms(w, m, objective)
+	fmt.Fprintf(w, "\nSubject To\n")
+	for _, c := range m.Constraints {
+		fmt.Fprintf(w, " %s:", c.Name)
+		writeTerms(w, m, c.Terms)
+		fmt.Fprintf(w, " %s %s\n", c.Sense, formatNumber(c.RHS))
+	}
+
+	fmt.Fprintf(w, "Binaries\n")
+	n := 0
+	for _, variable := range m.Variables {
+		if !variable.Binary {
+			continue
+		}
+		if n > 0 && n%termsPerLine == 0 {
+			fmt.Fprintf(w, "\n")
+		}
+		fmt.Fprintf(w, " %s", variable.Name)
+		n++
+	}
+	fmt.Fprintf(w, "\nEnd\n")
+	return w.Flush()
+}
+
+// WriteMPS writes the model in free MPS format, the binaries are the
+// integer columns between markers with an upper bound of one
+func (m *Model) WriteMPS(out io.Writer) error {
+	w := bufio.NewWriter(out)
+
+	senses := map[string]string{"=": "E", "<=": "L", ">=": "G"}
+	fmt.Fprintf(w, "NAME %s\n", m.Name)
+	fmt.Fprintf(w, "ROWS\n N obj\n")
+	for _, c := range m.Constraints {
+		fmt.Fprintf(w, " %s %s\n", senses[c.Sense], c.Name)
+	}
+
+	// MPS is column oriented
+	columns := make([][]Term, len(m.Variables))
+	for r, c := range m.Constraints {
+		for _, t := range c.Terms {
+			if t.Coef != 0 {
+				columns[t.Variable] = append(columns[t.Variable], Term{Variable: r, Coef: t.Coef})
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "COLUMNS\n")
+	integer := false
+	for v, variable := range m.Variables {
+		if variable.Binary != integer {
+			if variable.Binary {
+				fmt.Fprintf(w, " MARKER 'MARKER' 'INTORG'\n")
+			} else {
+				fmt.Fprintf(w, " MARKER 'MARKER' 'INTEND'\n")
+			}
+			integer = variable.Binary
+		}
+		if variable.Objective != 0 {
+			fmt.Fprintf(w, " %s obj %s\n", variable.Name, formatNumber(variable.Objective))
+		}
+		for _, t := range columns[v] {
+			fmt.Fprintf(w, " %s %s %s\n", variable.Name, m.Constraints[t.Variable].Name, formatNumber(t.Coef))
+		}
+		if variable.Objective == 0 && len(columns[v]) == 0 {
+			fmt.Fprintf(w, " %s obj 0\n", variable.Name)
+		}
+	}
+	if integer {
+		fmt.Fprintf(w, " MARKER 'MARKER' 'INTEND'\n")
+	}
+
+	fmt.Fprintf(w, "RHS\n")
+	for _, c := range m.Constraints {
+		if c.RHS != 0 {
+			fmt.Fprintf(w, " rhs %s %s\n", c.Name, formatNumber(c.RHS))
+		}
+	}
+
+	fmt.Fprintf(w, "BOUNDS\n")
+	for _, variable := range m.Variables {
+		if variable.Binary {
+			fmt.Fprintf(w, " BV bnd %s\n", variable.Name)
+		}
+	}
+	fmt.Fprintf(w, "ENDATA\n")
+	return w.Flush()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+var allocationPattern = regexp.MustCompile(`\bz_(\d+)_(\d+)\b(.*)`)
+var xmlValuePattern = regexp.MustCompile(`value="([^"]+)"`)
+var numberPattern = regexp.MustCompile(`[-+]?(\d+\.?\d*|\.\d+)([eE][-+]?\d+)?`)
+
+// readSolution reads the allocation variables from a solver's solution
+// file. The CPLEX XML format (value attribute) and the plain text formats
+// of Gurobi, CBC, SCIP and HiGHS (the value follows the name on the same
+// line) are understood, every other variable is ignored.
+func readSolution(location string, no_nodes int) (solution []int, err error) {
+	f, err := os.Open(location)
+	if err != nil {
+		return solution, err
+	}
+	defer f.Close()
+
+	solution = make([]int, no_nodes)
+	for i := range solution {
+		solution[i] = -1
+	}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for scanner.Scan() {
+		match := allocationPattern.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+		i, _ := strconv.Atoi(match[1])
+		k, _ := strconv.Atoi(match[2])
+
+		var text string
+		if value := xmlValuePattern.FindStringSubmatch(match[3]); value != nil {
+			text = value[1]
+		} else if text = numberPattern.FindString(match[3]); text == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return solution, err
+		}
+		if value < 0.5 {
+			continue
+		}
+
+		if i < 1 || i > no_nodes || k < 1 || k > no_nodes {
+			return solution, fmt.Errorf("z_%d_%d is out of range for %d nodes", i, k, no_nodes)
+		}
+		if solution[i-1] != -1 && solution[i-1] != k-1 {
+			return solution, fmt.Errorf("node %d is allocated to hubs %d and %d", i, solution[i-1]+1, k)
+		}
+		solution[i-1] = k - 1
+	}
+	if err = scanner.Err(); err != nil {
+		return solution, err
+	}
+
+	for i, hub := range solution {
+		if hub == -1 {
+			return solution, fmt.Errorf("node %d is not allocated", i+1)
+		}
+		if solution[hub] != hub {
+			return solution, fmt.Errorf("node %d is allocated to %d which is not a hub", i+1, hub+1)
+		}
+	}
+	return solution, nil
+}
+
+// the candidate of an imported solution, evaluated the same way the
+// heuristics evaluate theirs
+func candidateFromSolution(solution []int) Candidate {
+	c := Candidate{Solution: solution}
+	for i, hub := range solution {
+		if i == hub {
+			c.Hubs = append(c.Hubs, hub)
+		}
+	}
+	sort.Ints(c.Hubs)
+	c.calcCost(alpha)
+	return c
+}
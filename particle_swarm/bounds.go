@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+)
+
+// bounds on the optimal TNC written by the branch_and_bound program, the
+// gap of every configuration with a known bound is reported
+var boundsFile = "bounds.csv"
+var knownBounds = map[string]Bound{}
+
+// Bound is the best lower bound known for a configuration, Proven when it
+// is the optimum
+type Bound struct {
+	LowerBound float64
+	Proven     bool
+}
+
+func boundKey(dataset string, hubs int, alpha float64) string {
+	return fmt.Sprintf("%s|%d|%f", dataset, hubs, alpha)
+}
+
+// a missing file holds no bounds. When several methods bounded the same
+// configuration the proven optimum, or else the highest bound, is kept
+func loadBounds(location string) error {
+	f, err := os.Open(location)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return err
+	}
+	for n, record := range records {
+		if n == 0 || len(record) < 7 {
+			continue
+		}
+		hubs, err := strconv.Atoi(record[1])
+		if err != nil {
+			return err
+		}
+		alpha, err := strconv.ParseFloat(record[2], 64)
+		if err != nil {
+			return err
+		}
+		lower_bound, err := strconv.ParseFloat(record[4], 64)
+		if err != nil {
+			return err
+		}
+		proven, err := strconv.ParseBool(record[6])
+		if err != nil {
+			return err
+		}
+
+		key := boundKey(record[0], hubs, alpha)
+		known, ok := knownBounds[key]
+		if !ok || (proven && !known.Proven) || (proven == known.Proven && lower_bound > known.LowerBound) {
+			knownBounds[key] = Bound{LowerBound: lower_bound, Proven: proven}
+		}
+	}
+	return nil
+}
+
+// gap of a TNC to the optimum (opt) or to the best lower bound (lb)
+func formatGap(dataset string, hubs int, alpha float64, tnc float64) string {
+	b, ok := knownBounds[boundKey(dataset, hubs, alpha)]
+	if !ok {
+		return "-"
+	}
+	// the bounds are rounded when written, a gap within the rounding is
+	// none
+	gap := (tnc - b.LowerBound) / b.LowerBound * 100
+	if math.Abs(gap) < 1e-6 {
+		gap = 0
+	}
+	if b.Proven {
+		return fmt.Sprintf("%.4f%% opt", gap)
+	}
+	return fmt.Sprintf("%.4f%% lb", gap)
+}
@@ -0,0 +1,550 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var cost_matrix = [][]float64{}
+var flow_matrix = [][]float64{}
+var total_flow float64
+
+var no_hubs = 3
+var alpha = 0.8
+var no_routines = 10
+
+// Configurations
+var iterations = 200
+var swarmSize = 30
+var inertia = 0.729
+var cognitive = 1.49445
+var social = 1.49445
+var maxVelocity = 0.2
+var topology = "global"
+var allocationRule = "nearest"
+
+// iterations without improvement after which the swarm stops, the default
+// of -stall-limit
+var swarmStall = 50
+
+func read_matrix(location string, no_nodes int) (matrix [][]float64, err error) {
+	f, err := os.Open(location)
+	if err != nil {
+		return matrix, err
+	}
+	defer f.Close()
+
+	// Create a new reader.
+	r := csv.NewReader(bufio.NewReader(f))
+
+	row := []float64{}
+
+	for {
+		record, err := r.Read()
+
+		// Stop at EOF.
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return matrix, err
+		}
+		if len(matrix) == no_nodes || len(record) != no_nodes {
+			return matrix, fmt.Errorf("%s is not a %d x %d matrix, row %d has %d columns", location, no_nodes, no_nodes, len(matrix)+1, len(record))
+		}
+
+		for i := 0; i < no_nodes; i++ {
+			value, err := strconv.ParseFloat(record[i], 10)
+			if err != nil {
+				return matrix, err
+			} else {
+				row = append(row, value)
+			}
+		}
+		matrix = append(matrix, row)
+		row = []float64{}
+	}
+	if len(matrix) != no_nodes {
+		return matrix, fmt.Errorf("%s is not a %d x %d matrix, it has %d rows", location, no_nodes, no_nodes, len(matrix))
+	}
+
+	return matrix, nil
+}
+
+func calcTotalFlow(flow_matrix [][]float64) float64 {
+	total_flow := 0.0
+	for _, c := range flow_matrix {
+		for _, e := range c {
+			total_flow += e
+		}
+	}
+	return total_flow
+}
+
+// calculate total_cost follow Spoke-Hub-Hub-spoke strategy
+func calcTotalCost(cost_matrix, flow_matrix [][]float64, alpha float64, solution []int) float64 {
+	var total_cost float64
+	total_cost = 0.0
+	for i, _ := range flow_matrix {
+		for j, _ := range flow_matrix {
+			collection_cost := flow_matrix[i][j] * cost_matrix[i][solution[i]]
+			transportation_cost := flow_matrix[i][j] * cost_matrix[solution[i]][solution[j]] * alpha
+			distribution_cost := flow_matrix[i][j] * cost_matrix[solution[j]][j]
+			cost := collection_cost + transportation_cost + distribution_cost
+			total_cost += cost
+		}
+	}
+	return total_cost
+}
+
+// cost difference of moving a single node to another hub, only the pairs
+// starting or ending at the node are affected
+func calcReallocationDelta(solution []int, node, hub int) float64 {
+	runTermination.Evaluated()
+	old_hub := solution[node]
+	delta := 0.0
+	for j, _ := range flow_matrix {
+		if j == node {
+			continue
+		}
+		out_flow := flow_matrix[node][j]
+		in_flow := flow_matrix[j][node]
+		delta += out_flow * (cost_matrix[node][hub] - cost_matrix[node][old_hub])
+		delta += out_flow * alpha * (cost_matrix[hub][solution[j]] - cost_matrix[old_hub][solution[j]])
+		delta += in_flow * (cost_matrix[hub][node] - cost_matrix[old_hub][node])
+		delta += in_flow * alpha * (cost_matrix[solution[j]][hub] - cost_matrix[solution[j]][old_hub])
+	}
+	self_flow := flow_matrix[node][node]
+	delta += self_flow * (cost_matrix[node][hub] + cost_matrix[hub][node] - cost_matrix[node][old_hub] - cost_matrix[old_hub][node])
+	return delta
+}
+
+type Candidate struct {
+	Solution       []int
+	Cost           float64
+	Hubs           []int
+	NormalizedCost float64
+	ElapsedTime    time.Duration
+	Iteration      int
+	StopReason     string
+}
+
+type CandidateVector []Candidate
+
+func (c CandidateVector) Len() int {
+	return len(c)
+}
+
+func (c CandidateVector) Less(i, j int) bool {
+	return c[i].Cost < c[j].Cost
+}
+
+func (c CandidateVector) Swap(i, j int) {
+	c[j], c[i] = c[i], c[j]
+}
+
+func (c Candidate) Print() {
+	hubs := make([]string, len(c.Hubs))
+	for k, hub := range c.Hubs {
+		hubs[k] = strconv.Itoa(hub + 1)
+	}
+	fmt.Printf("Hubs: %s\n", strings.Join(hubs, " "))
+	fmt.Printf("Nodes:  \t")
+	for i, _ := range c.Solution {
+		fmt.Printf("%-2d\t", i+1)
+	}
+	fmt.Printf("\n")
+	fmt.Printf("Solution:\t")
+	for _, n := range c.Solution {
+		fmt.Printf("%-2d\t", n+1)
+	}
+	fmt.Printf("\nTotal Cost: %+v\n", c.Cost)
+	fmt.Printf("Normalized Cost: %+v\n", c.NormalizedCost)
+}
+
+func (c *Candidate) calcCost(alpha float64) {
+	runTermination.Evaluated()
+	c.Cost = calcTotalCost(cost_matrix, flow_matrix, alpha, c.Solution) + calcAllocationPenalty(c.Solution)
+	c.NormalizedCost = c.Cost / total_flow
+}
+
+// a spoke without a hub it may be allocated to pays the largest cost for
+// the total flow
+func calcAllocationPenalty(solution []int) float64 {
+	violations := 0
+	for i, hub := range solution {
+		if !canAllocate(i, hub) {
+			violations++
+		}
+	}
+	if violations == 0 {
+		return 0
+	}
+	largest := 0.0
+	for _, row := range cost_matrix {
+		for _, c := range row {
+			if c > largest {
+				largest = c
+			}
+		}
+	}
+	return float64(violations) * largest * total_flow
+}
+
+// Particle of the swarm, the position holds one priority key per node
+type Particle struct {
+	Position     []float64
+	Velocity     []float64
+	BestPosition []float64
+	BestCost     float64
+	Current      Candidate
+}
+
+// the nodes with the number_of_hubs highest keys become the hubs, forced
+// hubs first and forbidden nodes skipped
+func decodeHubs(keys []float64, number_of_hubs int) []int {
+	return decodeAllowedHubs(keys, number_of_hubs)
+}
+
+// allocate nodes to their nearest hubs they may be allocated to
+func allocateNearest(hubs []int) []int {
+	solution := make([]int, len(cost_matrix))
+	for i, _ := range cost_matrix {
+		allowed := allowedHubs(i, hubs)
+		target_hub := allowed[0]
+		for _, hub := range allowed {
+			if cost_matrix[i][hub] < cost_matrix[i][target_hub] {
+				target_hub = hub
+			}
+		}
+		solution[i] = target_hub
+	}
+	return solution
+}
+
+// start from the nearest allocation and move every spoke to the hub that
+// lowers the total cost the most until no move improves
+func allocateGreedy(hubs []int) []int {
+	solution := allocateNearest(hubs)
+	improved := true
+	for improved {
+		improved = false
+		for i, _ := range solution {
+			if isInSlice(i, hubs) {
+				continue
+			}
+			best_hub := solution[i]
+			best_delta := 0.0
+			for _, hub := range allowedHubs(i, hubs) {
+				if hub == solution[i] {
+					continue
+				}
+				delta := calcReallocationDelta(solution, i, hub)
+				if delta < best_delta-1e-9 {
+					best_delta = delta
+					best_hub = hub
+				}
+			}
+			if best_hub != solution[i] {
+				solution[i] = best_hub
+				improved = true
+			}
+		}
+	}
+	return solution
+}
+
+func decode(keys []float64, number_of_hubs int) Candidate {
+	candidate := Candidate{}
+	candidate.Hubs = decodeHubs(keys, number_of_hubs)
+	if allocationRule == "greedy" {
+		candidate.Solution = allocateGreedy(candidate.Hubs)
+	} else {
+		candidate.Solution = allocateNearest(candidate.Hubs)
+	}
+	candidate.calcCost(alpha)
+	return candidate
+}
+
+func isInSlice(a int, list []int) bool {
+	for _, b := range list {
+		if b == a {
+			return true
+		}
+	}
+	return false
+}
+
+func createParticle(no_nodes, number_of_hubs int) Particle {
+	p := Particle{}
+	p.Position = make([]float64, no_nodes)
+	p.Velocity = make([]float64, no_nodes)
+	for i := 0; i < no_nodes; i++ {
+		p.Position[i] = rand.Float64()
+		p.Velocity[i] = (rand.Float64()*2 - 1) * maxVelocity
+	}
+	p.Current = decode(p.Position, number_of_hubs)
+	p.BestPosition = make([]float64, no_nodes)
+	copy(p.BestPosition, p.Position)
+	p.BestCost = p.Current.Cost
+	return p
+}
+
+// index of the particle whose personal best guides particle i
+func neighbourhoodBest(swarm []Particle, i int) int {
+	if topology == "ring" {
+		best := i
+		for _, k := range []int{(i - 1 + len(swarm)) % len(swarm), (i + 1) % len(swarm)} {
+			if swarm[k].BestCost < swarm[best].BestCost {
+				best = k
+			}
+		}
+		return best
+	}
+	best := 0
+	for k, _ := range swarm {
+		if swarm[k].BestCost < swarm[best].BestCost {
+			best = k
+		}
+	}
+	return best
+}
+
+func clamp(value, low, high float64) float64 {
+	if value < low {
+		return low
+	}
+	if value > high {
+		return high
+	}
+	return value
+}
+
+func ParticleSwarm(cost_matrix, flow_matrix [][]float64, swarmSize, iterations int, alpha float64) (best Candidate) {
+	swarm := make([]Particle, swarmSize)
+	for i, _ := range swarm {
+		swarm[i] = createParticle(len(cost_matrix), no_hubs)
+		if i == 0 || swarm[i].Current.Cost < best.Cost {
+			best = swarm[i].Current
+		}
+	}
+
+	for it := 0; it < iterations; it++ {
+
+		if runTermination.Stop(it-best.Iteration, swarmStall, best.NormalizedCost) {
+			break
+		}
+
+		// the guides are chosen before moving so every particle of an
+		// iteration sees the same swarm memory
+		guides := make([]int, len(swarm))
+		for i, _ := range swarm {
+			guides[i] = neighbourhoodBest(swarm, i)
+		}
+
+		for i, _ := range swarm {
+			p := &swarm[i]
+			guide := swarm[guides[i]].BestPosition
+			for d, _ := range p.Position {
+				r1, r2 := rand.Float64(), rand.Float64()
+				v := inertia*p.Velocity[d] +
+					cognitive*r1*(p.BestPosition[d]-p.Position[d]) +
+					social*r2*(guide[d]-p.Position[d])
+				p.Velocity[d] = clamp(v, -maxVelocity, maxVelocity)
+				p.Position[d] = clamp(p.Position[d]+p.Velocity[d], 0, 1)
+			}
+
+			p.Current = decode(p.Position, no_hubs)
+			if p.Current.Cost < p.BestCost {
+				p.BestCost = p.Current.Cost
+				copy(p.BestPosition, p.Position)
+			}
+			if p.Current.Cost < best.Cost {
+				best = p.Current
+				best.Iteration = it
+			}
+		}
+	}
+
+	return best
+}
+
+func main() {
+
+	var err error
+
+	flag.IntVar(&iterations, "iterations", iterations, "maximum number of swarm iterations")
+	flag.IntVar(&swarmSize, "swarm", swarmSize, "number of particles")
+	flag.Float64Var(&inertia, "inertia", inertia, "inertia weight")
+	flag.Float64Var(&cognitive, "cognitive", cognitive, "cognitive (personal best) coefficient")
+	flag.Float64Var(&social, "social", social, "social (neighbourhood best) coefficient")
+	flag.Float64Var(&maxVelocity, "vmax", maxVelocity, "velocity clamp")
+	flag.StringVar(&topology, "topology", topology, "neighbourhood topology: global or ring")
+	flag.StringVar(&allocationRule, "allocation", allocationRule, "allocation of spokes to the decoded hubs: nearest or greedy")
+	flag.StringVar(&boundsFile, "bounds", boundsFile, "CSV file of known bounds written by branch_and_bound, used to report the gap")
+	flag.StringVar(&constraintsFile, "hub-constraints", constraintsFile, "CSV of forced,NODE, forbidden,NODE and forbidden_allocation,SPOKE,HUB rows, nodes numbered from 1")
+	flag.StringVar(&forcedOption, "forced-hubs", forcedOption, "comma separated nodes, numbered from 1, that are always hubs")
+	flag.StringVar(&forbiddenOption, "forbidden-hubs", forbiddenOption, "comma separated nodes, numbered from 1, that are never hubs")
+	flag.StringVar(&forbiddenAllocationOption, "forbidden-allocations", forbiddenAllocationOption, "comma separated SPOKE:HUB pairs, numbered from 1, of allocations that are not allowed")
+	flag.DurationVar(&timeLimit, "time-limit", timeLimit, "wall clock limit of every run, e.g. 30s")
+	flag.Int64Var(&maxEvaluations, "max-evaluations", maxEvaluations, "budget of cost evaluations of every run")
+	flag.Float64Var(&targetCost, "target-cost", targetCost, "normalized cost at which a run stops")
+	flag.IntVar(&stallLimit, "stall-limit", swarmStall, "iterations without improvement after which a run stops, 0 keeps the limit of the swarm")
+	flag.Parse()
+
+	// an interrupt stops the running search, the results so far are kept
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	rootContext = ctx
+
+	err = checkTermination()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+	err = loadHubConstraints()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	// the known bounds do not hold under hub constraints
+	if !hubConstraints() {
+		err = loadBounds(boundsFile)
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			return
+		}
+	}
+
+	if topology != "global" && topology != "ring" {
+		fmt.Printf("Error: unknown topology %q\n", topology)
+		return
+	}
+	if allocationRule != "nearest" && allocationRule != "greedy" {
+		fmt.Printf("Error: unknown allocation %q\n", allocationRule)
+		return
+	}
+
+	data_sets_flow := []string{
+		"Flow_matrix10.csv",
+		"Flow_matrix15.csv",
+		"Flow_matrix20.csv",
+		"Flow_matrix25.csv",
+		"postal_office_network_flow_25.csv",
+		"postal_office_network_flow_55.csv",
+	}
+	data_sets_cost := []string{
+		"Cost_matrix10.csv",
+		"Cost_matrix15.csv",
+		"Cost_matrix20.csv",
+		"Cost_matrix25.csv",
+		"postal_office_network_distance_25.csv",
+		"postal_office_network_distance_55.csv",
+	}
+	sizes := []int{
+		10,
+		15,
+		20,
+		25,
+		25,
+		55,
+	}
+
+	alphas := []float64{
+		0.2,
+		0.4,
+		0.8,
+	}
+	hubs := []int{
+		3,
+		4,
+	}
+
+	fmt.Printf("Confirguration: Iterations[%d]\tSwarm Size[%d]\tInertia[%0.3f]\tCognitive[%0.3f]\tSocial[%0.3f]\tMax Velocity[%0.3f]\tTopology[%s]\tAllocation[%s]\n", iterations, swarmSize, inertia, cognitive, social, maxVelocity, topology, allocationRule)
+	if hubConstraints() {
+		printHubConstraints()
+	}
+	if terminationLimits() {
+		printTermination()
+	}
+	fmt.Printf("%-40s\t%-10s\t%-10s\t%-20s\t%-20s\t%-20s\t%-20s\t%-20s\t%-20s\t%-20s\t%-20s\n", "Datset", "No Hubs", "Alpha", "Hub Locations", "TNC", "Gap", "Avg TNC", "Time Per Run", "Total Time", "Iterations", "Stop Reason")
+datasets:
+	for i, _ := range data_sets_flow {
+		// data sets without the constrained nodes are skipped
+		if constrainedNodes() > sizes[i] {
+			continue
+		}
+		for _, hub := range hubs {
+			err = checkHubConstraints(sizes[i], hub)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err.Error())
+				return
+			}
+		}
+
+		// read input data
+		cost_matrix, err = read_matrix(data_sets_cost[i], sizes[i])
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			return
+		}
+
+		flow_matrix, err = read_matrix(data_sets_flow[i], sizes[i])
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			return
+		}
+
+		// calc total flow
+		total_flow = calcTotalFlow(flow_matrix)
+
+		for _, hub := range hubs {
+			no_hubs = hub
+			for _, alfa := range alphas {
+
+				// update the global variable used
+				alpha = alfa
+				var best []Candidate
+
+				fmt.Printf("%-40s\t%-10d\t%-10f\t", data_sets_cost[i], no_hubs, alpha)
+				primary_start_time := time.Now()
+				for k := 0; k < no_routines; k++ {
+					start := time.Now()
+					runTermination = newTermination(rootContext)
+					c := ParticleSwarm(cost_matrix, flow_matrix, swarmSize, iterations, alpha)
+					c.ElapsedTime = time.Since(start)
+					c.StopReason = runTermination.Done()
+					runTermination = nil
+					best = append(best, c)
+				}
+
+				sort.Sort(CandidateVector(best))
+
+				// average TNC
+				average_tnc := 0.0
+				for _, c := range best {
+					average_tnc += c.NormalizedCost
+				}
+				average_tnc = average_tnc / float64(len(best))
+				gap := formatGap(data_sets_cost[i], no_hubs, alpha, best[0].NormalizedCost)
+				fmt.Printf("%-v\t%-20f\t%-20s\t%-20f\t%-20s\t%-20s\t%-20d\t%-20s\n", best[0].Hubs, best[0].NormalizedCost, gap, average_tnc, best[0].ElapsedTime, time.Since(primary_start_time), best[0].Iteration, best[0].StopReason)
+				// after an interrupt the remaining configurations are left out
+				if rootContext.Err() != nil {
+					break datasets
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// allocation strategy of the spokes: single allocates every spoke to one
+// hub, multiple routes every O-D pair through its cheapest pair of hubs and
+// r links every spoke to at most maxLinks hubs
+var allocation = "single"
+var maxLinks = 1
+
+// parseAllocation reads the allocation option: single, multiple or r=K
+func parseAllocation(value string) error {
+	if value == "single" || value == "multiple" {
+		allocation = value
+		return nil
+	}
+	if strings.HasPrefix(value, "r=") {
+		r, err := strconv.Atoi(strings.TrimPrefix(value, "r="))
+		if err != nil || r < 1 {
+			return fmt.Errorf("invalid allocation %q, r must be a positive number", value)
+		}
+		allocation = "r"
+		maxLinks = r
+		return nil
+	}
+	return fmt.Errorf("unknown allocation %q", value)
+}
+
+func allocationName() string {
+	if allocation == "r" {
+		return fmt.Sprintf("r=%d", maxLinks)
+	}
+	return allocation
+}
+
+// total cost of the hubs when every O-D pair takes the cheapest hub path
+// i -> k -> m -> j among the chosen hubs i and j may be allocated to
+func calcMultipleAllocationCost(hubs []int) float64 {
+	n := len(cost_matrix)
+	// to_hub[i][m] is the cheapest way from i to hub m through a first hub
+	to_hub := make([][]float64, n)
+	for i := 0; i < n; i++ {
+		to_hub[i] = make([]float64, n)
+		for _, m := range hubs {
+			best := math.Inf(1)
+			for _, k := range allowedHubs(i, hubs) {
+				c := cost_matrix[i][k] + alpha*cost_matrix[k][m]
+				if c < best {
+					best = c
+				}
+			}
+			to_hub[i][m] = best
+		}
+	}
+
+	total_cost := 0.0
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if flow_matrix[i][j] == 0 {
+				continue
+			}
+			best := math.Inf(1)
+			for _, m := range allowedHubs(j, hubs) {
+				c := to_hub[i][m] + cost_matrix[m][j]
+				if c < best {
+					best = c
+				}
+			}
+			total_cost += flow_matrix[i][j] * best
+		}
+	}
+	return total_cost
+}
+
+// total cost of an r-allocation, every O-D pair takes the cheapest path
+// from a hub linked to its origin to a hub linked to its destination
+func calcRAllocationCost(links [][]int) float64 {
+	total_cost := 0.0
+	for i, from := range links {
+		for j, to := range links {
+			if flow_matrix[i][j] == 0 {
+				continue
+			}
+			best := math.Inf(1)
+			for _, k := range from {
+				for _, m := range to {
+					c := cost_matrix[i][k] + alpha*cost_matrix[k][m] + cost_matrix[m][j]
+					if c < best {
+						best = c
+					}
+				}
+			}
+			total_cost += flow_matrix[i][j] * best
+		}
+	}
+	return total_cost
+}
+
+// cost of a solution under the configured objective, forbidden
+// allocations and hub changes over the warm start limit are penalised
+func calcSolutionCost(solution, hubs []int, links [][]int, arcs [][2]int) float64 {
+	runTermination.Evaluated()
+	penalty := calcAllocationPenalty(solution, hubs, links) + calcChangePenalty(hubs)
+	switch objective {
+	case "center":
+		return calcCenterCost(solution, hubs, links) + penalty
+	case "covering":
+		return calcCoveringCost(solution, hubs, links) + penalty
+	}
+	return calcMedianCost(solution, hubs, links, arcs) + penalty
+}
+
+// spokes allocated to a hub they are forbidden, under multiple allocation
+// the spokes without an allowed hub
+func calcViolations(solution, hubs []int, links [][]int) int {
+	violations := 0
+	switch allocation {
+	case "multiple":
+		return unallocatable(hubs)
+	case "r":
+		for i, l := range links {
+			for _, hub := range l {
+				if !canAllocate(i, hub) {
+					violations++
+					break
+				}
+			}
+		}
+		return violations
+	}
+	for i, hub := range solution {
+		if !canAllocate(i, hub) {
+			violations++
+		}
+	}
+	return violations
+}
+
+// the largest cost for every violation, scaled by the flow under the
+// median objective
+func calcAllocationPenalty(solution, hubs []int, links [][]int) float64 {
+	if len(forbiddenAllocations) == 0 {
+		return 0
+	}
+	penalty := float64(calcViolations(solution, hubs, links)) * maxCost()
+	if objective == "median" {
+		penalty *= total_flow
+	}
+	return penalty
+}
+
+// total cost of a solution under the configured allocation strategy
+// including the fixed costs of the hubs, the multiple allocation cost only
+// depends on the hubs. A capacitated single allocation also pays the
+// overload penalty, economies of scale and incomplete hub networks only
+// apply to single allocation. Under flow scenarios it is the robust cost.
+func calcMedianCost(solution, hubs []int, links [][]int, arcs [][2]int) float64 {
+	if robust() {
+		return calcRobustCost(solution, hubs, links, arcs)
+	}
+	switch allocation {
+	case "multiple":
+		return calcMultipleAllocationCost(hubs) + calcFixedCost(hubs)
+	case "r":
+		return calcRAllocationCost(links) + calcFixedCost(hubs)
+	}
+	if scaleModel != "constant" {
+		return calcScaledCost(solution) + calcCapacityCost(solution, hubs)
+	}
+	if incompleteNetwork() {
+		return calcNetworkCost(solution, hubs, arcs) + calcCapacityCost(solution, hubs)
+	}
+	return calcTotalCost(cost_matrix, flow_matrix, alpha, solution) + calcCapacityCost(solution, hubs)
+}
+
+// move the spokes allocated against a forbidden allocation to their
+// nearest allowed hub
+func repairAllocation(solution, hubs []int) {
+	var nearest []int
+	for i, hub := range solution {
+		if !canAllocate(i, hub) {
+			if nearest == nil {
+				nearest = allocateNearest(hubs)
+			}
+			solution[i] = nearest[i]
+		}
+	}
+}
+
+// a hub is only linked to itself, a spoke to its maxLinks nearest hubs it
+// may be allocated to
+func nearestLinks(node int, hubs []int) []int {
+	if isInSlice(node, hubs) {
+		return []int{node}
+	}
+	sorted := append([]int{}, allowedHubs(node, hubs)...)
+	sort.SliceStable(sorted, func(a, b int) bool {
+		return cost_matrix[node][sorted[a]] < cost_matrix[node][sorted[b]]
+	})
+	if len(sorted) > maxLinks {
+		sorted = sorted[:maxLinks]
+	}
+	return sorted
+}
+
+func allocateLinks(hubs []int) [][]int {
+	links := make([][]int, len(cost_matrix))
+	for i := range links {
+		links[i] = nearestLinks(i, hubs)
+	}
+	return links
+}
+
+func cloneLinks(links [][]int) [][]int {
+	if links == nil {
+		return nil
+	}
+	clone := make([][]int, len(links))
+	for i, l := range links {
+		clone[i] = make([]int, len(l))
+		copy(clone[i], l)
+	}
+	return clone
+}
+
+// the links after hub old_hub is moved to node new_hub, the spokes linked
+// to the old hub are linked to the new one
+func relinkHub(links [][]int, old_hub, new_hub int) {
+	for i := range links {
+		for k, hub := range links[i] {
+			if hub == old_hub {
+				links[i][k] = new_hub
+			}
+		}
+	}
+	links[new_hub] = []int{new_hub}
+}
+
+// change the hub links of a spoke by a random move: add a link to another
+// allowed hub, remove one of its links or swap a link for an allowed hub it
+// is not linked to. intn draws the random numbers.
+func moveLink(links [][]int, hubs []int, node int, intn func(n int) int) {
+	var others []int
+	for _, hub := range allowedHubs(node, hubs) {
+		if !isInSlice(hub, links[node]) {
+			others = append(others, hub)
+		}
+	}
+
+	var moves []string
+	if len(others) > 0 && len(links[node]) < maxLinks {
+		moves = append(moves, "add")
+	}
+	if len(links[node]) > 1 {
+		moves = append(moves, "remove")
+	}
+	if len(others) > 0 {
+		moves = append(moves, "swap")
+	}
+	if len(moves) == 0 {
+		return
+	}
+
+	switch moves[intn(len(moves))] {
+	case "add":
+		links[node] = append(links[node], others[intn(len(others))])
+	case "remove":
+		k := intn(len(links[node]))
+		links[node] = append(links[node][:k], links[node][k+1:]...)
+	case "swap":
+		links[node][intn(len(links[node]))] = others[intn(len(others))]
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// node attributes of the capacitated problem, one row per node holding the
+// hub capacity on incoming flow and the fixed cost of opening the hub. An
+// empty file name leaves the problem uncapacitated.
+var capacityFile = ""
+var capacities = []float64{}
+var fixedCosts = []float64{}
+
+// cost of every unit of flow over a hub capacity, relative to the largest
+// cost of the cost matrix
+var capacityPenalty = 2.0
+
+// fixed hub opening costs of the uncapacitated hub location problem, one
+// row per node. With them the solvers choose the number of hubs.
+var fixedCostFile = ""
+
+func capacitated() bool {
+	return len(capacities) > 0
+}
+
+// the covering objective minimises the number of hubs as well
+func freeHubs() bool {
+	return fixedCostFile != "" || objective == "covering"
+}
+
+// readFixedCosts reads one fixed cost per node from the last column of
+// every row, so node attribute files can be read as well. A leading header
+// row is skipped.
+func readFixedCosts(location string) (fixed []float64, err error) {
+	f, err := os.Open(location)
+	if err != nil {
+		return fixed, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return fixed, err
+	}
+	for n, record := range records {
+		if len(record) == 0 {
+			continue
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(record[len(record)-1]), 64)
+		if err != nil {
+			if n == 0 {
+				continue
+			}
+			return fixed, err
+		}
+		fixed = append(fixed, value)
+	}
+	return fixed, nil
+}
+
+// readNodeAttributes reads the capacity,fixed_cost rows of a node
+// attribute file, a leading header row is skipped
+func readNodeAttributes(location string) (capacity, fixed []float64, err error) {
+	f, err := os.Open(location)
+	if err != nil {
+		return capacity, fixed, err
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return capacity, fixed, err
+	}
+	for n, record := range records {
+		if len(record) < 2 {
+			return capacity, fixed, fmt.Errorf("%s line %d: expected capacity,fixed_cost", location, n+1)
+		}
+		c, err := strconv.ParseFloat(strings.TrimSpace(record[0]), 64)
+		if err != nil {
+			if n == 0 {
+				continue
+			}
+			return capacity, fixed, err
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if err != nil {
+			return capacity, fixed, err
+		}
+		capacity = append(capacity, c)
+		fixed = append(fixed, f)
+	}
+	return capacity, fixed, nil
+}
+
+// flow every node sends, the load it puts on its hub
+func calcOutflow(i int) float64 {
+	outflow := 0.0
+	for _, w := range flow_matrix[i] {
+		outflow += w
+	}
+	return outflow
+}
+
+// incoming flow of every hub of a single allocation
+func calcHubLoads(solution []int) []float64 {
+	loads := make([]float64, len(solution))
+	for i, hub := range solution {
+		loads[hub] += calcOutflow(i)
+	}
+	return loads
+}
+
+func calcOverload(solution, hubs []int) float64 {
+	loads := calcHubLoads(solution)
+	overload := 0.0
+	for _, hub := range hubs {
+		if loads[hub] > capacities[hub] {
+			overload += loads[hub] - capacities[hub]
+		}
+	}
+	return overload
+}
+
+func maxCost() float64 {
+	max := 0.0
+	for _, row := range cost_matrix {
+		for _, c := range row {
+			if c > max {
+				max = c
+			}
+		}
+	}
+	return max
+}
+
+// fixed costs of the hubs, zero when there are none
+func calcFixedCost(hubs []int) float64 {
+	cost := 0.0
+	if len(fixedCosts) == 0 {
+		return cost
+	}
+	for _, hub := range hubs {
+		cost += fixedCosts[hub]
+	}
+	return cost
+}
+
+// fixed costs of the hubs plus the penalty of the flow over capacity
+func calcCapacityCost(solution, hubs []int) float64 {
+	if !capacitated() {
+		return calcFixedCost(hubs)
+	}
+	return calcFixedCost(hubs) + capacityPenalty*maxCost()*calcOverload(solution, hubs)
+}
+
+// allocate the spokes in decreasing order of their flow to the nearest
+// allowed hub that still has room for it, or to the nearest allowed hub
+// when none has
+func allocateCapacitated(hubs []int) []int {
+	n := len(cost_matrix)
+	solution := make([]int, n)
+	remaining := make([]float64, n)
+	for _, hub := range hubs {
+		solution[hub] = hub
+		remaining[hub] = capacities[hub] - calcOutflow(hub)
+	}
+
+	var spokes []int
+	for i := 0; i < n; i++ {
+		if !isInSlice(i, hubs) {
+			spokes = append(spokes, i)
+		}
+	}
+	sortByOutflow(spokes)
+
+	for _, i := range spokes {
+		outflow := calcOutflow(i)
+		nearest, fitting := -1, -1
+		for _, hub := range allowedHubs(i, hubs) {
+			if nearest == -1 || cost_matrix[i][hub] < cost_matrix[i][nearest] {
+				nearest = hub
+			}
+			if remaining[hub] >= outflow && (fitting == -1 || cost_matrix[i][hub] < cost_matrix[i][fitting]) {
+				fitting = hub
+			}
+		}
+		if fitting == -1 {
+			fitting = nearest
+		}
+		solution[i] = fitting
+		remaining[fitting] -= outflow
+	}
+	return solution
+}
+
+func sortByOutflow(nodes []int) {
+	sort.SliceStable(nodes, func(a, b int) bool {
+		return calcOutflow(nodes[a]) > calcOutflow(nodes[b])
+	})
+}
+
+// a hub of the solution node may be allocated to with room for its flow,
+// -1 when none has
+func hubWithRoom(solution, hubs []int, node int, intn func(n int) int) int {
+	loads := calcHubLoads(solution)
+	outflow := calcOutflow(node)
+	var fitting []int
+	for _, hub := range allowedHubs(node, hubs) {
+		if hub != solution[node] && loads[hub]+outflow <= capacities[hub] {
+			fitting = append(fitting, hub)
+		}
+	}
+	if len(fitting) == 0 {
+		return -1
+	}
+	return fitting[intn(len(fitting))]
+}
+
+// printUtilisation reports the load of every hub against its capacity
+func printUtilisation(solution, hubs []int) {
+	loads := calcHubLoads(solution)
+	fixed := 0.0
+	fmt.Printf("%-40s\tUtilisation:", "")
+	for _, hub := range hubs {
+		fixed += fixedCosts[hub]
+		fmt.Printf(" %d[%.2f/%.2f %.2f%%]", hub+1, loads[hub], capacities[hub], loads[hub]/capacities[hub]*100)
+	}
+	fmt.Printf("\tFixed Cost[%f]\tFeasible[%t]\n", fixed, calcOverload(solution, hubs) == 0)
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+// evaluate command configuration, the instance and the solution file
+var evalCostFile = "Cost_matrix10.csv"
+var evalFlowFile = "Flow_matrix10.csv"
+var evalNodes = 10
+var evalSolutionFile = ""
+var evalPaths = true
+
+// checkSolution lists what makes a single allocation solution infeasible:
+// nodes beyond the instance, repeated hubs, spokes allocated to a node
+// that is not a hub, hubs not allocated to themselves, another number of
+// hubs than p (unless p is 0), the hub constraints and the capacities
+func checkSolution(hubs, solution []int, p int) []string {
+	var violations []string
+	n := len(cost_matrix)
+	if len(solution) != n {
+		return append(violations, fmt.Sprintf("the solution has %d nodes, the instance %d", len(solution), n))
+	}
+	for i, hub := range solution {
+		if hub >= n {
+			violations = append(violations, fmt.Sprintf("node %d is allocated to node %d beyond the %d nodes", i+1, hub+1, n))
+		}
+	}
+	for _, hub := range hubs {
+		if hub >= n {
+			violations = append(violations, fmt.Sprintf("hub %d is beyond the %d nodes", hub+1, n))
+		}
+	}
+	if len(violations) > 0 {
+		return violations
+	}
+
+	for k, hub := range hubs {
+		if isInSlice(hub, hubs[:k]) {
+			violations = append(violations, fmt.Sprintf("hub %d is listed twice", hub+1))
+		}
+	}
+	for i, hub := range solution {
+		if !isInSlice(hub, hubs) {
+			violations = append(violations, fmt.Sprintf("node %d is allocated to node %d which is not a hub", i+1, hub+1))
+		}
+		if !canAllocate(i, hub) {
+			violations = append(violations, fmt.Sprintf("node %d is allocated to the forbidden hub %d", i+1, hub+1))
+		}
+	}
+	for k, hub := range hubs {
+		if isInSlice(hub, hubs[:k]) {
+			continue
+		}
+		if solution[hub] != hub {
+			violations = append(violations, fmt.Sprintf("hub %d is allocated to node %d instead of itself", hub+1, solution[hub]+1))
+		}
+		if !canBeHub(hub) {
+			violations = append(violations, fmt.Sprintf("hub %d is a forbidden hub", hub+1))
+		}
+	}
+	for _, node := range forcedHubs {
+		if !isInSlice(node, hubs) {
+			violations = append(violations, fmt.Sprintf("the forced hub %d is not a hub", node+1))
+		}
+	}
+	if p > 0 && len(hubs) != p {
+		violations = append(violations, fmt.Sprintf("the solution has %d hubs instead of %d", len(hubs), p))
+	}
+	if capacitated() {
+		loads := calcHubLoads(solution)
+		for k, hub := range hubs {
+			if isInSlice(hub, hubs[:k]) {
+				continue
+			}
+			if loads[hub] > capacities[hub] {
+				violations = append(violations, fmt.Sprintf("hub %d carries %.2f over its capacity %.2f", hub+1, loads[hub], capacities[hub]))
+			}
+		}
+	}
+	return violations
+}
+
+// evaluate is the evaluate command, it checks a single allocation solution
+// of an instance and prices it without running a search
+func evaluate(args []string) error {
+	fs := flag.NewFlagSet("evaluate", flag.ExitOnError)
+	fs.StringVar(&evalCostFile, "cost", evalCostFile, "cost matrix CSV")
+	fs.StringVar(&evalFlowFile, "flow", evalFlowFile, "flow matrix CSV")
+	fs.IntVar(&evalNodes, "nodes", evalNodes, "number of nodes of the instance")
+	fs.Float64Var(&alpha, "alpha", alpha, "discount factor of the hub to hub transport")
+	fs.IntVar(&no_hubs, "hubs", 0, "number of hubs the solution must have, 0 for any")
+	fs.StringVar(&evalSolutionFile, "solution", evalSolutionFile, "solution file with Hubs: and Solution: lines, nodes numbered from 1")
+	fs.BoolVar(&evalPaths, "paths", evalPaths, "report the path and cost of every O-D pair")
+	fs.StringVar(&fixedCostFile, "fixed-costs", fixedCostFile, "CSV of the hub fixed cost per node (last column), added to the cost")
+	fs.StringVar(&capacityFile, "capacities", capacityFile, "CSV of capacity,fixed_cost per node, the hub loads are checked against them")
+	fs.StringVar(&constraintsFile, "hub-constraints", constraintsFile, "CSV of forced,NODE, forbidden,NODE and forbidden_allocation,SPOKE,HUB rows, nodes numbered from 1")
+	fs.StringVar(&forcedOption, "forced-hubs", forcedOption, "comma separated nodes, numbered from 1, that are always hubs")
+	fs.StringVar(&forbiddenOption, "forbidden-hubs", forbiddenOption, "comma separated nodes, numbered from 1, that are never hubs")
+	fs.StringVar(&forbiddenAllocationOption, "forbidden-allocations", forbiddenAllocationOption, "comma separated SPOKE:HUB pairs, numbered from 1, of allocations that are not allowed")
+	fs.Parse(args)
+
+	if evalSolutionFile == "" {
+		return fmt.Errorf("no solution file given")
+	}
+
+	var err error
+	cost_matrix, err = read_matrix(evalCostFile, evalNodes)
+	if err != nil {
+		return err
+	}
+	flow_matrix, err = read_matrix(evalFlowFile, evalNodes)
+	if err != nil {
+		return err
+	}
+	total_flow = calcTotalFlow(flow_matrix)
+
+	if capacityFile != "" {
+		capacities, fixedCosts, err = readNodeAttributes(capacityFile)
+		if err != nil {
+			return err
+		}
+	} else if fixedCostFile != "" {
+		fixedCosts, err = readFixedCosts(fixedCostFile)
+		if err != nil {
+			return err
+		}
+	}
+	if len(fixedCosts) > 0 && len(fixedCosts) != evalNodes {
+		return fmt.Errorf("the fixed costs have %d nodes, the instance %d", len(fixedCosts), evalNodes)
+	}
+	err = loadHubConstraints()
+	if err != nil {
+		return err
+	}
+	err = checkHubConstraints(evalNodes, 0)
+	if err != nil {
+		return err
+	}
+
+	hubs, solution, err := readSolutionFile(evalSolutionFile)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Evaluation: Cost[%s]\tFlow[%s]\tNodes[%d]\tAlpha[%f]\tSolution[%s]\n", evalCostFile, evalFlowFile, evalNodes, alpha, evalSolutionFile)
+	labels := make([]int, len(hubs))
+	for k, hub := range hubs {
+		labels[k] = hub + 1
+	}
+	violations := checkSolution(hubs, solution, no_hubs)
+	fmt.Printf("Hubs%v\tFeasible[%t]\tViolations[%d]\n", labels, len(violations) == 0, len(violations))
+	for _, v := range violations {
+		fmt.Printf("Violation: %s\n", v)
+	}
+	// the costs need every node allocated to a node of the instance, a
+	// repeated hub is priced once
+	if len(solution) != evalNodes {
+		return nil
+	}
+	unique := []int{}
+	for _, hub := range append(append([]int{}, hubs...), solution...) {
+		if hub >= evalNodes {
+			return nil
+		}
+		if isInSlice(hub, hubs) && !isInSlice(hub, unique) {
+			unique = append(unique, hub)
+		}
+	}
+	hubs = unique
+
+	// spokes allocated to a node that is not a hub are routed through it
+	nodes := append([]int{}, hubs...)
+	for _, hub := range solution {
+		if !isInSlice(hub, nodes) {
+			nodes = append(nodes, hub)
+		}
+	}
+	topRoutes = 0
+	if evalPaths {
+		topRoutes = evalNodes * evalNodes
+	}
+	r := buildReport(evalCostFile, "", 0, solution, nodes, nil, nil)
+	fixed := 0.0
+	if len(fixedCosts) > 0 {
+		fixed = calcFixedCost(hubs)
+	}
+	r.Value = (r.Total + fixed) / total_flow
+	r.Print()
+	if len(fixedCosts) > 0 {
+		fmt.Printf("%-40s\tFixed Cost: Hubs[%f]\tTotal[%f]\tTNC[%f]\n", "", fixed, r.Total+fixed, r.Value)
+	}
+	if capacitated() {
+		printUtilisation(solution, hubs)
+	}
+	return nil
+}
@@ -2,12 +2,17 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/csv"
+	"flag"
 	"fmt"
 	"io"
+	"math"
 	"math/rand"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
 	"time"
 
 	// "hash/fnv"
@@ -31,7 +36,11 @@ var maxCandidatesMultiplier = 5
 var aspiration = 4
 
 func read_matrix(location string, no_nodes int) (matrix [][]float64, err error) {
-	f, _ := os.Open(location)
+	f, err := os.Open(location)
+	if err != nil {
+		return matrix, err
+	}
+	defer f.Close()
 
 	// Create a new reader.
 	r := csv.NewReader(bufio.NewReader(f))
@@ -45,6 +54,12 @@ func read_matrix(location string, no_nodes int) (matrix [][]float64, err error)
 		if err == io.EOF {
 			break
 		}
+		if err != nil {
+			return matrix, err
+		}
+		if len(matrix) == no_nodes || len(record) != no_nodes {
+			return matrix, fmt.Errorf("%s is not a %d x %d matrix, row %d has %d columns", location, no_nodes, no_nodes, len(matrix)+1, len(record))
+		}
 
 		for i := 0; i < no_nodes; i++ {
 			value, err := strconv.ParseFloat(record[i], 10)
@@ -57,8 +72,11 @@ func read_matrix(location string, no_nodes int) (matrix [][]float64, err error)
 		matrix = append(matrix, row)
 		row = []float64{}
 	}
+	if len(matrix) != no_nodes {
+		return matrix, fmt.Errorf("%s is not a %d x %d matrix, it has %d rows", location, no_nodes, no_nodes, len(matrix))
+	}
 
-	return matrix, err
+	return matrix, nil
 }
 
 func init() {
@@ -79,8 +97,11 @@ type Candidate struct {
 	Hubs           []int
 	NormalizedCost float64
 	SwappedNode    int
+	Links          [][]int  // hubs of every node under r-allocation
+	Arcs           [][2]int // installed hub arcs of an incomplete hub network
 	ElapsedTime    time.Duration
 	Iteration      int
+	StopReason     string
 }
 
 type CandidateVector []Candidate
@@ -99,7 +120,11 @@ func (c CandidateVector) Swap(i, j int) {
 
 func (c Candidate) Print() {
 	// fmt.Fprintln(os.Stderr, "")
-	fmt.Printf("Hubs: %+v\n", c.Hubs)
+	hubs := make([]string, len(c.Hubs))
+	for k, hub := range c.Hubs {
+		hubs[k] = strconv.Itoa(hub + 1)
+	}
+	fmt.Printf("Hubs: %s\n", strings.Join(hubs, " "))
 	fmt.Printf("Nodes:  \t")
 	for i, _ := range c.Solution {
 		fmt.Printf("%-2d\t", i+1)
@@ -109,6 +134,22 @@ func (c Candidate) Print() {
 	for _, n := range c.Solution {
 		fmt.Printf("%-2d\t", n+1)
 	}
+	if c.Links != nil {
+		fmt.Printf("\nLinks:  \t")
+		for _, l := range c.Links {
+			labels := make([]string, len(l))
+			for k, hub := range l {
+				labels[k] = strconv.Itoa(hub + 1)
+			}
+			fmt.Printf("%-2s\t", strings.Join(labels, ","))
+		}
+	}
+	if c.Arcs != nil {
+		fmt.Printf("\nHub Arcs:\t")
+		for _, a := range c.Arcs {
+			fmt.Printf("%d-%d\t", a[0]+1, a[1]+1)
+		}
+	}
 	fmt.Printf("\nTotal Cost: %+v\n", c.Cost)
 	fmt.Printf("Normalized Cost: %+v\n", c.NormalizedCost)
 
@@ -123,38 +164,70 @@ func (c Candidate) PrintTable() {
 
 }
 
-func (c *Candidate) calcCost(alpha float64) {
-	c.Cost = calcTotalCost(cost_matrix, flow_matrix, alpha, c.Solution)
-	c.NormalizedCost = c.Cost / total_flow
+func (c *Candidate) calcCost() {
+	c.Cost = calcSolutionCost(c.Solution, c.Hubs, c.Links, c.Arcs)
+	// the reliable hubs of the whatif command minimise the expected cost
+	if reliableSearch {
+		c.Cost = calcExpectedCost(c.Solution, c.Hubs) + calcAllocationPenalty(c.Solution, c.Hubs, c.Links)
+	}
+	c.NormalizedCost = normalize(c.Cost)
 }
 
 func get_initial_solution(cost_matrix, flow_matrix [][]float64, alpha float64, number_of_hubs int) Candidate {
+	// a warm start begins at the incumbent
+	if warmStart() {
+		return incumbentCandidate()
+	}
+
 	candidate := Candidate{}
 
-	// randomly select certain number of hubs
+	// randomly select certain number of hubs, when the number is free
+	// between two and a quarter of the nodes. The forced hubs come first.
 	rand.Seed(time.Now().UnixNano())
+	if number_of_hubs == 0 {
+		number_of_hubs = 2 + rand.Intn(len(cost_matrix)/4)
+		if number_of_hubs < len(forcedHubs) {
+			number_of_hubs = len(forcedHubs)
+		}
+	}
+	candidate.Hubs = append(candidate.Hubs, forcedHubs...)
 	for len(candidate.Hubs) < number_of_hubs {
 		random_number := rand.Intn(len(cost_matrix))
-		if !isInSlice(random_number, candidate.Hubs) {
+		if !isInSlice(random_number, candidate.Hubs) && canBeHub(random_number) {
 			candidate.Hubs = append(candidate.Hubs, random_number)
 		}
 	}
 
 	// allocate nodes to their nearest candidate.Hubs
-	for i, _ := range cost_matrix {
-		target_hub := candidate.Hubs[0]
-		for _, hub := range candidate.Hubs {
-			if cost_matrix[i][hub] < cost_matrix[i][target_hub] {
-				target_hub = hub
-			}
-		}
-		candidate.Solution = append(candidate.Solution, target_hub)
+	candidate.Solution = allocateNearest(candidate.Hubs)
+
+	if allocation == "r" {
+		candidate.Links = allocateLinks(candidate.Hubs)
+	}
+	if capacitated() {
+		candidate.Solution = allocateCapacitated(candidate.Hubs)
+	}
+	if incompleteNetwork() {
+		candidate.Arcs = buildArcs(candidate.Hubs)
 	}
 
 	return candidate
 }
 
-//  calculate total_cost follow Spoke-Hub-Hub-spoke strategy
+// the warm start solution with the links and arcs of its hubs
+func incumbentCandidate() Candidate {
+	candidate := Candidate{}
+	candidate.Hubs, candidate.Solution = incumbent()
+	if allocation == "r" {
+		candidate.Links = allocateLinks(candidate.Hubs)
+	}
+	if incompleteNetwork() {
+		candidate.Arcs = buildArcs(candidate.Hubs)
+	}
+	return candidate
+}
+
+// calculate total_cost follow Spoke-Hub-Hub-spoke strategy
 func calcTotalCost(cost_matrix, flow_matrix [][]float64, alpha float64, solution []int) float64 {
 	var total_cost float64
 	total_cost = 0.0
@@ -170,6 +243,33 @@ func calcTotalCost(cost_matrix, flow_matrix [][]float64, alpha float64, solution
 	return total_cost
 }
 
+// allocate nodes to the nearest hub they may be allocated to
+func allocateNearest(hubs []int) []int {
+	solution := make([]int, len(cost_matrix))
+	for i, _ := range cost_matrix {
+		allowed := allowedHubs(i, hubs)
+		target_hub := allowed[0]
+		for _, hub := range allowed {
+			if cost_matrix[i][hub] < cost_matrix[i][target_hub] {
+				target_hub = hub
+			}
+		}
+		solution[i] = target_hub
+	}
+	return solution
+}
+
+// the candidate or, when that is cheaper, its hubs with every node
+// allocated to its nearest hub
+func nearestCandidate(c Candidate) Candidate {
+	nearest := Candidate{Hubs: c.Hubs, Solution: allocateNearest(c.Hubs)}
+	nearest.calcCost()
+	if nearest.Cost < c.Cost {
+		return nearest
+	}
+	return c
+}
+
 func calcTotalFlow(flow_matrix [][]float64) float64 {
 	total_flow := 0.0
 	for _, c := range flow_matrix {
@@ -197,13 +297,33 @@ func selectRandomNodeAndHub(best Candidate) (int, int) {
 		selected_node = rand.Intn(len(best.Solution))
 	}
 
-	// select another random hub to assign to
-	selected_hub := best.Hubs[rand.Intn(len(best.Hubs))]
-	for selected_hub == best.Solution[selected_node] {
-		selected_hub = best.Hubs[rand.Intn(len(best.Hubs))]
+	// select another random hub the node may be assigned to, its own hub
+	// when there is none
+	var others []int
+	for _, hub := range allowedHubs(selected_node, best.Hubs) {
+		if hub != best.Solution[selected_node] {
+			others = append(others, hub)
+		}
+	}
+	if len(others) == 0 {
+		return selected_node, best.Solution[selected_node]
 	}
 
-	return selected_node, selected_hub
+	return selected_node, others[rand.Intn(len(others))]
+}
+
+// a random spoke that may replace its hub, -1 when none may
+func selectSwappableNode(best Candidate) int {
+	var nodes []int
+	for i, hub := range best.Solution {
+		if !isInSlice(i, best.Hubs) && canSwapHub(i, hub) {
+			nodes = append(nodes, i)
+		}
+	}
+	if len(nodes) == 0 {
+		return -1
+	}
+	return nodes[rand.Intn(len(nodes))]
 }
 
 func updateTabuList(node int, tabuList *[]int, tabuSize int) {
@@ -222,7 +342,14 @@ func generateCandidateTypeA(current_solution Candidate) (c Candidate, swapped_no
 	copy(neighbor.Solution, current_solution.Solution)
 	copy(neighbor.Hubs, current_solution.Hubs)
 
-	random_node, _ := selectRandomNodeAndHub(neighbor)
+	// forced hubs stay and forbidden nodes never become hubs
+	random_node := selectSwappableNode(neighbor)
+	if random_node == -1 {
+		neighbor.Links = cloneLinks(current_solution.Links)
+		neighbor.Arcs = current_solution.Arcs
+		random_node, _ = selectRandomNodeAndHub(neighbor)
+		return neighbor, random_node
+	}
 
 	hub_to_switch := neighbor.Solution[random_node]
 
@@ -238,6 +365,21 @@ func generateCandidateTypeA(current_solution Candidate) (c Candidate, swapped_no
 		}
 	}
 
+	if current_solution.Links != nil {
+		neighbor.Links = cloneLinks(current_solution.Links)
+		relinkHub(neighbor.Links, hub_to_switch, random_node)
+	}
+	if current_solution.Arcs != nil {
+		neighbor.Arcs = cloneArcs(current_solution.Arcs)
+		relinkArcs(neighbor.Arcs, hub_to_switch, random_node)
+	}
+
+	// the new hub has another capacity, the spokes are allocated again
+	if capacitated() {
+		neighbor.Solution = allocateCapacitated(neighbor.Hubs)
+	}
+	repairAllocation(neighbor.Solution, neighbor.Hubs)
+
 	return neighbor, random_node
 }
 
@@ -256,6 +398,7 @@ func generateCandidateTypeB(current_solution Candidate) (c Candidate, swapped_no
 	hub_node_1 := neighbor.Solution[random_node_1]
 	neighbor.Solution[random_node_1] = neighbor.Solution[random_node_2]
 	neighbor.Solution[random_node_2] = hub_node_1
+	repairAllocation(neighbor.Solution, neighbor.Hubs)
 
 	return neighbor, random_node_1
 }
@@ -276,6 +419,120 @@ func generateCandidateTypeC(current_solution Candidate) (c Candidate, swapped_no
 	return neighbor, random_node
 }
 
+// add, remove or swap a hub link of a random node under r-allocation
+func generateCandidateTypeD(current_solution Candidate) (c Candidate, swapped_node int) {
+	neighbor := Candidate{}
+
+	neighbor.Solution = make([]int, len(current_solution.Solution))
+	neighbor.Hubs = make([]int, len(current_solution.Hubs))
+	copy(neighbor.Solution, current_solution.Solution)
+	copy(neighbor.Hubs, current_solution.Hubs)
+	neighbor.Links = cloneLinks(current_solution.Links)
+
+	random_node, _ := selectRandomNodeAndHub(neighbor)
+
+	moveLink(neighbor.Links, neighbor.Hubs, random_node, rand.Intn)
+	neighbor.Solution[random_node] = neighbor.Links[random_node][0]
+
+	return neighbor, random_node
+}
+
+// reallocate a random spoke to a hub with room for its flow, spokes of
+// overloaded hubs are moved first
+func generateCandidateTypeE(current_solution Candidate) (c Candidate, swapped_node int) {
+	neighbor := Candidate{}
+
+	neighbor.Solution = make([]int, len(current_solution.Solution))
+	neighbor.Hubs = make([]int, len(current_solution.Hubs))
+	copy(neighbor.Solution, current_solution.Solution)
+	copy(neighbor.Hubs, current_solution.Hubs)
+	neighbor.Arcs = current_solution.Arcs
+
+	loads := calcHubLoads(neighbor.Solution)
+	var overloaded []int
+	for i, hub := range neighbor.Solution {
+		if i != hub && loads[hub] > capacities[hub] {
+			overloaded = append(overloaded, i)
+		}
+	}
+
+	random_node, random_hub := selectRandomNodeAndHub(neighbor)
+	if len(overloaded) > 0 {
+		random_node = overloaded[rand.Intn(len(overloaded))]
+	}
+	if hub := hubWithRoom(neighbor.Solution, neighbor.Hubs, random_node, rand.Intn); hub != -1 {
+		random_hub = hub
+	}
+
+	neighbor.Solution[random_node] = random_hub
+	repairAllocation(neighbor.Solution, neighbor.Hubs)
+
+	return neighbor, random_node
+}
+
+// open a random node as a hub or close a random hub, the nodes are
+// allocated to their nearest hub again. At least two hubs stay open and
+// one node stays a spoke, forced hubs are never closed and forbidden nodes
+// never opened.
+func generateCandidateTypeF(current_solution Candidate) (c Candidate, swapped_node int) {
+	neighbor := Candidate{}
+
+	neighbor.Hubs = make([]int, len(current_solution.Hubs))
+	copy(neighbor.Hubs, current_solution.Hubs)
+
+	var closable, openable []int
+	for k, hub := range neighbor.Hubs {
+		if !isForced(hub) {
+			closable = append(closable, k)
+		}
+	}
+	for i := range current_solution.Solution {
+		if !isInSlice(i, neighbor.Hubs) && canBeHub(i) {
+			openable = append(openable, i)
+		}
+	}
+
+	var node int
+	can_open := len(neighbor.Hubs) < len(current_solution.Solution)-1 && len(openable) > 0
+	can_close := len(neighbor.Hubs) > 2 && len(closable) > 0
+	if can_close && (!can_open || rand.Intn(2) == 0) {
+		k := closable[rand.Intn(len(closable))]
+		node = neighbor.Hubs[k]
+		neighbor.Hubs = append(neighbor.Hubs[:k], neighbor.Hubs[k+1:]...)
+	} else if can_open {
+		node = openable[rand.Intn(len(openable))]
+		neighbor.Hubs = append(neighbor.Hubs, node)
+	} else {
+		node, _ = selectRandomNodeAndHub(current_solution)
+	}
+
+	neighbor.Solution = allocateNearest(neighbor.Hubs)
+	if current_solution.Links != nil {
+		neighbor.Links = allocateLinks(neighbor.Hubs)
+	}
+	if current_solution.Arcs != nil {
+		neighbor.Arcs = buildArcs(neighbor.Hubs)
+	}
+
+	return neighbor, node
+}
+
+// drop a hub arc of an incomplete hub network and add another one, the
+// hub of the added arc becomes tabu
+func generateCandidateTypeG(current_solution Candidate) (c Candidate, swapped_node int) {
+	neighbor := Candidate{}
+
+	neighbor.Solution = make([]int, len(current_solution.Solution))
+	neighbor.Hubs = make([]int, len(current_solution.Hubs))
+	copy(neighbor.Solution, current_solution.Solution)
+	copy(neighbor.Hubs, current_solution.Hubs)
+
+	arcs, node := moveArc(current_solution.Arcs, neighbor.Hubs, rand.Intn)
+	neighbor.Arcs = arcs
+
+	return neighbor, node
+}
+
 func TabuSearch(initial_solution Candidate, cost_matrix, flow_matrix [][]float64, tabuSize, maxCandidates, iterations int, alpha float64) (best Candidate) {
 	current := initial_solution
 	best = current
@@ -284,18 +541,37 @@ func TabuSearch(initial_solution Candidate, cost_matrix, flow_matrix [][]float64
 
 	for i := 0; i < iterations; i++ {
 
-		if i-best.Iteration > 10000 {
+		if runTermination.Stop(i-best.Iteration, 10000, best.NormalizedCost) {
 			break
 		}
 
 		var candidates []Candidate
 		for j := 0; j < maxCandidates; j++ {
 			neighbor, swapped_node := generateCandidateTypeA(current)
+			if allocation == "r" && rand.Intn(2) == 0 {
+				neighbor, swapped_node = generateCandidateTypeD(current)
+			}
+			if capacitated() && rand.Intn(2) == 0 {
+				neighbor, swapped_node = generateCandidateTypeE(current)
+			}
+			if freeHubs() && rand.Intn(2) == 0 {
+				neighbor, swapped_node = generateCandidateTypeF(current)
+			}
+			if incompleteNetwork() && rand.Intn(2) == 0 {
+				neighbor, swapped_node = generateCandidateTypeG(current)
+			}
 
+			// a warm start never moves past the max changes
+			if !withinChanges(neighbor.Hubs) {
+				continue
+			}
 			neighbor.SwappedNode = swapped_node
-			neighbor.calcCost(alpha)
+			neighbor.calcCost()
 			candidates = append(candidates, neighbor)
 		}
+		if len(candidates) == 0 {
+			continue
+		}
 
 		sort.Sort(CandidateVector(candidates))
 		bestCandidate := candidates[0]
@@ -321,7 +597,12 @@ func TabuSearch(initial_solution Candidate, cost_matrix, flow_matrix [][]float64
 				best.Iteration = i
 			}
 		}
-
+		// the list starts out full of node 0, its entries are the moves so far
+		entries := i + 1
+		if entries > tabuSize {
+			entries = tabuSize
+		}
+		runTrace.Add(i, current.NormalizedCost, best.NormalizedCost, entries, 0)
 	}
 
 	return best
@@ -331,6 +612,196 @@ func main() {
 
 	var err error
 
+	// evaluate checks and prices a given solution without a search
+	if len(os.Args) > 1 && os.Args[1] == "evaluate" {
+		err = evaluate(os.Args[2:])
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+		}
+		return
+	}
+	// whatif fails the hubs of a given solution one at a time
+	if len(os.Args) > 1 && os.Args[1] == "whatif" {
+		err = whatif(os.Args[2:])
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+		}
+		return
+	}
+	// sensitivity sweeps alpha for a range of hub numbers
+	if len(os.Args) > 1 && os.Args[1] == "sensitivity" {
+		err = sensitivity(os.Args[2:])
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+		}
+		return
+	}
+
+	flag.StringVar(&boundsFile, "bounds", boundsFile, "CSV file of known bounds written by branch_and_bound, used to report the gap")
+	allocationOption := allocation
+	flag.StringVar(&allocationOption, "allocation", allocationOption, "allocation of the spokes: single, multiple or r=K for at most K hubs per spoke")
+	flag.StringVar(&capacityFile, "capacities", capacityFile, "CSV of capacity,fixed_cost per node, solves the capacitated problem on the data sets of that many nodes")
+	flag.Float64Var(&capacityPenalty, "capacity-penalty", capacityPenalty, "penalty per unit of flow over a hub capacity, relative to the largest cost")
+	flag.StringVar(&fixedCostFile, "fixed-costs", fixedCostFile, "CSV of the hub fixed cost per node (last column), the number of hubs is then chosen by the search")
+	flag.StringVar(&objective, "objective", objective, "objective: median (total cost), center (largest route cost) or covering (fewest hubs within -cover-radius)")
+	flag.Float64Var(&coverRadius, "cover-radius", coverRadius, "largest route cost of a covered O-D pair under the covering objective")
+	flag.StringVar(&hubNetwork, "hub-network", hubNetwork, "network between the hubs: complete, arcs (-hub-arcs arcs), tree or star")
+	flag.IntVar(&hubArcs, "hub-arcs", hubArcs, "number of hub arcs installed by the arcs hub network")
+	flag.StringVar(&scenarioFiles, "scenarios", scenarioFiles, "comma separated flow matrix files of the flow scenarios, they replace the flows of the data sets of that many nodes")
+	flag.StringVar(&intervalFiles, "flow-intervals", intervalFiles, "lower,upper flow matrix files of interval flows, turned into flow scenarios")
+	flag.IntVar(&intervalSamples, "interval-samples", intervalSamples, "random scenarios with every flow at one of its interval bounds")
+	flag.StringVar(&robustCriterion, "robust", robustCriterion, "criterion over the flow scenarios: expected, minmax or regret")
+	flag.StringVar(&constraintsFile, "hub-constraints", constraintsFile, "CSV of forced,NODE, forbidden,NODE and forbidden_allocation,SPOKE,HUB rows, nodes numbered from 1")
+	flag.StringVar(&forcedOption, "forced-hubs", forcedOption, "comma separated nodes, numbered from 1, that are always hubs")
+	flag.StringVar(&forbiddenOption, "forbidden-hubs", forbiddenOption, "comma separated nodes, numbered from 1, that are never hubs")
+	flag.StringVar(&forbiddenAllocationOption, "forbidden-allocations", forbiddenAllocationOption, "comma separated SPOKE:HUB pairs, numbered from 1, of allocations that are not allowed")
+	flag.StringVar(&initialFile, "initial-solution", initialFile, "solution file with Hubs: and Solution: lines, nodes numbered from 1, the search starts from")
+	flag.IntVar(&maxChanges, "max-changes", maxChanges, "most hubs that may differ from the initial solution, -1 for no limit")
+	flag.StringVar(&periodFiles, "periods", periodFiles, "comma separated flow matrix files, one per period, solves the multi-period problem on the data sets of that many nodes")
+	flag.Float64Var(&discountRate, "discount-rate", discountRate, "discount rate of the costs of every later period")
+	flag.Float64Var(&openingCost, "opening-cost", openingCost, "cost of opening a hub, relative to the normalized cost of the first period")
+	flag.Float64Var(&closingCost, "closing-cost", closingCost, "cost of closing a hub, relative to the normalized cost of the first period. A moved hub pays the opening and the closing cost, there is no relocation cost of its own")
+	flag.BoolVar(&allowClosing, "allow-closing", allowClosing, "let hubs close in a later period, hubs otherwise stay open once opened")
+	flag.StringVar(&scaleModel, "scale-model", scaleModel, "inter-hub cost: constant (alpha), flowloc (piecewise linear in the hub pair flow) or threshold (alpha above -scale-threshold)")
+	flag.StringVar(&scaleBreakpoints, "scale-breakpoints", scaleBreakpoints, "comma separated hub pair flows, as fractions of the total flow, where the flowloc slope falls towards alpha")
+	flag.Float64Var(&scaleThreshold, "scale-threshold", scaleThreshold, "hub pair flow, as a fraction of the total flow, from which the threshold model discounts by alpha")
+	flag.BoolVar(&reportText, "report", reportText, "report the cost breakdown, hub flows and costliest routes of the best solutions")
+	flag.StringVar(&reportJSON, "report-json", reportJSON, "JSON file the reports of the best solutions are written to")
+	flag.IntVar(&topRoutes, "top-routes", topRoutes, "number of the costliest O-D routes in the reports")
+	flag.StringVar(&traceDir, "trace-dir", traceDir, "directory the convergence trace of every run is written to")
+	flag.StringVar(&traceFormat, "trace-format", traceFormat, "format of the convergence traces: csv or jsonl")
+	flag.DurationVar(&timeLimit, "time-limit", timeLimit, "wall clock limit of every run, e.g. 30s")
+	flag.Int64Var(&maxEvaluations, "max-evaluations", maxEvaluations, "budget of cost evaluations of every run")
+	flag.Float64Var(&targetCost, "target-cost", targetCost, "normalized cost at which a run stops")
+	flag.IntVar(&stallLimit, "stall-limit", stallLimit, "iterations without improvement after which a run stops, 0 keeps the limit of the search")
+	flag.Parse()
+
+	// an interrupt stops the running search, the results so far are kept
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	rootContext = ctx
+
+	err = parseAllocation(allocationOption)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+	err = checkTermination()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+	err = checkTrace()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+	if topRoutes < 0 {
+		fmt.Printf("Error: the number of top routes must be 0 or more, got %d\n", topRoutes)
+		return
+	}
+
+	if capacityFile != "" {
+		capacities, fixedCosts, err = readNodeAttributes(capacityFile)
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			return
+		}
+		if allocation != "single" {
+			fmt.Printf("Error: capacities are only supported with single allocation\n")
+			return
+		}
+	}
+
+	if fixedCostFile != "" {
+		if capacitated() {
+			fmt.Printf("Error: the number of hubs of the capacitated problem is fixed, use the fixed costs of the capacities file\n")
+			return
+		}
+		fixedCosts, err = readFixedCosts(fixedCostFile)
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			return
+		}
+	}
+
+	err = checkObjective()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+	if objective != "median" && (capacityFile != "" || fixedCostFile != "") {
+		fmt.Printf("Error: capacities and fixed costs need the median objective\n")
+		return
+	}
+
+	err = parseScale()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+	if scaleModel != "constant" && (objective != "median" || allocation != "single") {
+		fmt.Printf("Error: economies of scale need the median objective and single allocation\n")
+		return
+	}
+
+	err = checkHubNetwork()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+	if incompleteNetwork() && (objective != "median" || allocation != "single" || scaleModel != "constant") {
+		fmt.Printf("Error: incomplete hub networks need the median objective, single allocation and a constant alpha\n")
+		return
+	}
+
+	err = loadScenarios()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+	if robust() && (objective != "median" || capacitated() || scaleModel != "constant") {
+		fmt.Printf("Error: flow scenarios need the median objective, no capacities and a constant alpha\n")
+		return
+	}
+
+	err = loadHubConstraints()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	if initialFile != "" {
+		err = loadIncumbent()
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			return
+		}
+	}
+
+	if periodFiles != "" {
+		err = loadPeriods()
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			return
+		}
+		if objective != "median" || robust() {
+			fmt.Printf("Error: multiple periods need the median objective and exact flows\n")
+			return
+		}
+	}
+
+	// the known bounds are single allocation median optima without fixed
+	// costs, with a constant alpha, a complete hub network and exact flows
+	// of a single period without hub constraints
+	if objective == "median" && allocation == "single" && len(fixedCosts) == 0 && scaleModel == "constant" && !incompleteNetwork() && !robust() && !multiPeriod() && !hubConstraints() {
+		err = loadBounds(boundsFile)
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			return
+		}
+	}
+
 	data_sets_flow := []string{
 		"Flow_matrix10.csv",
 		"Flow_matrix15.csv",
@@ -367,10 +838,73 @@ func main() {
 		3,
 		4,
 	}
+	// 0 lets the search choose the number of hubs, a warm start keeps the
+	// number of hubs of the incumbent
+	if freeHubs() {
+		hubs = []int{0}
+	} else if warmStart() {
+		hubs = []int{len(incumbentHubs)}
+	}
 
-	fmt.Printf("Confirguration: Iterations[%d]\tMax Candidates Multiplier[%d]\tTabu Size Divider[%d]\tAspiration[%d]\n", iterations, maxCandidatesMultiplier, tabuSizeDivider, aspiration)
-	fmt.Printf("%-40s\t%-10s\t%-10s\t%-20s\t%-20s\t%-20s\t%-20s\t%-20s\t%-20s\n", "Datset", "No Hubs", "Alpha", "Hub Locations", "TNC", "Avg TNC", "Time Per Run", "Total Time", "Iterations")
+	fmt.Printf("Confirguration: Iterations[%d]\tMax Candidates Multiplier[%d]\tTabu Size Divider[%d]\tAspiration[%d]\tAllocation[%s]\tObjective[%s]\n", iterations, maxCandidatesMultiplier, tabuSizeDivider, aspiration, allocationName(), objective)
+	if fixedCostFile != "" {
+		fmt.Printf("Fixed Costs: File[%s]\tNodes[%d]\n", fixedCostFile, len(fixedCosts))
+	}
+	if scaleModel == "flowloc" {
+		fmt.Printf("Economies of Scale: Model[%s]\tBreakpoints[%s]\n", scaleModel, scaleBreakpoints)
+	}
+	if scaleModel == "threshold" {
+		fmt.Printf("Economies of Scale: Model[%s]\tThreshold[%0.3f]\n", scaleModel, scaleThreshold)
+	}
+	if hubNetwork == "arcs" {
+		fmt.Printf("Hub Network: Shape[%s]\tArcs[%d]\n", hubNetwork, hubArcs)
+	} else if incompleteNetwork() {
+		fmt.Printf("Hub Network: Shape[%s]\n", hubNetwork)
+	}
+	if hubConstraints() {
+		printHubConstraints()
+	}
+	if warmStart() {
+		printWarmStart()
+	}
+	if multiPeriod() {
+		fmt.Printf("Periods: Count[%d]\tDiscount Rate[%0.3f]\tOpening Cost[%0.3f]\tClosing Cost[%0.3f]\tAllow Closing[%t]\n", len(periodFlows), discountRate, openingCost, closingCost, allowClosing)
+	}
+	if robust() {
+		fmt.Printf("Robust: Criterion[%s]\tScenarios[%d]\n", robustCriterion, len(scenarios))
+	}
+	if capacitated() {
+		fmt.Printf("Capacities: File[%s]\tNodes[%d]\tPenalty[%0.3f]\n", capacityFile, len(capacities), capacityPenalty)
+	}
+	if terminationLimits() {
+		printTermination()
+	}
+	fmt.Printf("%-40s\t%-10s\t%-10s\t%-20s\t%-20s\t%-20s\t%-20s\t%-20s\t%-20s\t%-20s\t%-20s\n", "Datset", "No Hubs", "Alpha", "Hub Locations", objectiveColumn(), "Gap", "Avg "+objectiveColumn(), "Time Per Run", "Total Time", "Iterations", "Stop Reason")
+datasets:
 	for i, _ := range data_sets_flow {
+		if len(fixedCosts) > 0 && len(fixedCosts) != sizes[i] {
+			continue
+		}
+		if robust() && len(scenarios[0]) != sizes[i] {
+			continue
+		}
+		if multiPeriod() && len(periodFlows[0]) != sizes[i] {
+			continue
+		}
+		if warmStart() && len(incumbentSolution) != sizes[i] {
+			continue
+		}
+		// data sets without the constrained nodes are skipped
+		if constrainedNodes() > sizes[i] {
+			continue
+		}
+		for _, hub := range hubs {
+			err = checkHubConstraints(sizes[i], hub)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err.Error())
+				return
+			}
+		}
 
 		// dynamic configurations
 		tabuSize := sizes[i] / tabuSizeDivider
@@ -379,40 +913,92 @@ func main() {
 		// read input data
 		cost_matrix, err = read_matrix(data_sets_cost[i], sizes[i])
 		if err != nil {
-			fmt.Printf("Error", err.Error())
+			fmt.Printf("Error: %s\n", err.Error())
 			return
 		}
 
 		flow_matrix, err = read_matrix(data_sets_flow[i], sizes[i])
 		if err != nil {
-			fmt.Printf("Error", err.Error())
+			fmt.Printf("Error: %s\n", err.Error())
 			return
 		}
 
 		// calc total flow
 		total_flow = calcTotalFlow(flow_matrix)
+		if robust() {
+			flow_matrix = meanScenario()
+			total_flow = calcTotalFlow(flow_matrix)
+		}
+		// the costs of a multi-period plan are normalized by the flow of
+		// its first period
+		if multiPeriod() {
+			flow_matrix = periodFlows[0]
+			total_flow = calcTotalFlow(flow_matrix)
+		}
 
 		for _, hub := range hubs {
 			no_hubs = hub
 			for _, alfa := range alphas {
+				// after an interrupt the remaining configurations, multi
+				// period plans included, are left out
+				if rootContext.Err() != nil {
+					break datasets
+				}
 
 				// update the global variable used
 				alpha = alfa
 				var best []Candidate
 
-				fmt.Printf("%-40s\t%-10d\t%-10f\t", data_sets_cost[i], no_hubs, alpha)
+				if multiPeriod() {
+					runSchedules(data_sets_cost[i], tabuSize)
+					continue
+				}
+
+				// the regret is measured against the best cost of every
+				// scenario on its own
+				if robust() && robustCriterion == "regret" {
+					scenarioOptima = solveScenarios(func() float64 {
+						optimum := math.Inf(1)
+						for k := 0; k < no_routines; k++ {
+							init_solution := get_initial_solution(cost_matrix, flow_matrix, alpha, no_hubs)
+							init_solution.calcCost()
+							c := TabuSearch(init_solution, cost_matrix, flow_matrix, tabuSize, maxCandidates, iterations, alpha)
+							optimum = math.Min(optimum, c.Cost)
+						}
+						return optimum
+					})
+				}
+
 				primary_start_time := time.Now()
 				for k := 0; k < no_routines; k++ {
 					start = time.Now()
+					if traceDir != "" {
+						runTrace = newTrace()
+					}
+					runTermination = newTermination(rootContext)
 					init_solution := get_initial_solution(cost_matrix, flow_matrix, alpha, no_hubs)
-					init_solution.calcCost(alpha)
+					init_solution.calcCost()
 					c := TabuSearch(init_solution, cost_matrix, flow_matrix, tabuSize, maxCandidates, iterations, alpha)
 					elapsed := time.Since(start)
 					c.ElapsedTime = elapsed
+					c.StopReason = runTermination.Done()
+					runTermination = nil
 					best = append(best, c)
+					if runTrace != nil {
+						err = writeTrace(traceFile(data_sets_cost[i], "ts", no_hubs, k), runTrace)
+						runTrace = nil
+						if err != nil {
+							fmt.Printf("Error: %s\n", err.Error())
+							return
+						}
+					}
 				}
 
 				sort.Sort(CandidateVector(best))
+				// a run past the max changes is never the best
+				sort.SliceStable(best, func(a, b int) bool {
+					return withinChanges(best[a].Hubs) && !withinChanges(best[b].Hubs)
+				})
 
 				// average TNC
 				average_tnc := 0.0
@@ -420,8 +1006,42 @@ func main() {
 					average_tnc += c.NormalizedCost
 				}
 				average_tnc = average_tnc / float64(len(best))
-				fmt.Printf("%-v\t%-20f\t%-20f\t%-20s\t%-20s\t%-20d\n", best[0].Hubs, best[0].NormalizedCost, average_tnc, best[0].ElapsedTime, time.Since(primary_start_time), best[0].Iteration)
+				gap := formatGap(data_sets_cost[i], no_hubs, alpha, best[0].NormalizedCost)
+				fmt.Printf("%-40s\t%-10d\t%-10f\t", data_sets_cost[i], len(best[0].Hubs), alpha)
+				fmt.Printf("%-v\t%-20f\t%-20s\t%-20f\t%-20s\t%-20s\t%-20d\t%-20s\n", best[0].Hubs, best[0].NormalizedCost, gap, average_tnc, best[0].ElapsedTime, time.Since(primary_start_time), best[0].Iteration, best[0].StopReason)
+				if capacitated() {
+					printUtilisation(best[0].Solution, best[0].Hubs)
+				}
+				if objective == "covering" {
+					printCoverage(best[0].Solution, best[0].Hubs, best[0].Links)
+				}
+				if incompleteNetwork() {
+					printArcs(best[0].Hubs, best[0].Arcs)
+				}
+				if robust() {
+					printScenarios(best[0].Solution, best[0].Hubs, best[0].Links, best[0].Arcs)
+				}
+				if warmStart() {
+					c := incumbentCandidate()
+					c.calcCost()
+					fmt.Printf("%-40s\tIncumbent[%f]\tImprovement[%f]\tChanged Hubs[%d]\tWithin Max Changes[%t]\n", "", c.NormalizedCost, c.NormalizedCost-best[0].NormalizedCost, hubChanges(best[0].Hubs), withinChanges(best[0].Hubs))
+				}
+				if reportText || reportJSON != "" {
+					r := buildReport(data_sets_cost[i], "", best[0].NormalizedCost, best[0].Solution, best[0].Hubs, best[0].Links, best[0].Arcs)
+					if reportText {
+						r.Print()
+					}
+					reports = append(reports, r)
+				}
 			}
 		}
 	}
+
+	if reportJSON != "" {
+		err = writeReportJSON(reportJSON, reports)
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			return
+		}
+	}
 }
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// objective optimised by the search: median minimises the total cost,
+// center the largest route cost of an O-D pair with flow, and covering the
+// number of hubs such that every O-D pair with flow is routed within
+// coverRadius
+var objective = "median"
+var coverRadius = 0.0
+
+var objectiveNames = []string{"median", "center", "covering"}
+
+func checkObjective() error {
+	for _, name := range objectiveNames {
+		if name == objective {
+			if objective == "covering" && coverRadius <= 0 {
+				return fmt.Errorf("the covering objective needs a positive cover radius")
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown objective %q", objective)
+}
+
+// column heading of the objective value in the result table
+func objectiveColumn() string {
+	switch objective {
+	case "center":
+		return "Max Route Cost"
+	case "covering":
+		return "Covering Cost"
+	}
+	return "TNC"
+}
+
+// the reported value of a cost, the total cost is normalized by the flow
+func normalize(cost float64) float64 {
+	if objective == "median" {
+		return cost / total_flow
+	}
+	return cost
+}
+
+// cost of routing a unit from i to j, through the hubs of i and j under
+// single allocation and the cheapest allowed pair of hubs otherwise
+func calcRouteCost(i, j int, solution, hubs []int, links [][]int) float64 {
+	switch allocation {
+	case "multiple":
+		return cheapestRoute(i, j, allowedHubs(i, hubs), allowedHubs(j, hubs))
+	case "r":
+		return cheapestRoute(i, j, links[i], links[j])
+	}
+	return cost_matrix[i][solution[i]] + alpha*cost_matrix[solution[i]][solution[j]] + cost_matrix[solution[j]][j]
+}
+
+func cheapestRoute(i, j int, from, to []int) float64 {
+	best := math.Inf(1)
+	for _, k := range from {
+		for _, m := range to {
+			c := cost_matrix[i][k] + alpha*cost_matrix[k][m] + cost_matrix[m][j]
+			if c < best {
+				best = c
+			}
+		}
+	}
+	return best
+}
+
+// largest route cost of the O-D pairs with flow
+func calcCenterCost(solution, hubs []int, links [][]int) float64 {
+	max := 0.0
+	for i := range flow_matrix {
+		for j := range flow_matrix {
+			if flow_matrix[i][j] == 0 {
+				continue
+			}
+			if c := calcRouteCost(i, j, solution, hubs, links); c > max {
+				max = c
+			}
+		}
+	}
+	return max
+}
+
+// O-D pairs with flow routed over the cover radius
+func calcUncovered(solution, hubs []int, links [][]int) int {
+	uncovered := 0
+	for i := range flow_matrix {
+		for j := range flow_matrix {
+			if flow_matrix[i][j] != 0 && calcRouteCost(i, j, solution, hubs, links) > coverRadius {
+				uncovered++
+			}
+		}
+	}
+	return uncovered
+}
+
+// number of hubs, every uncovered pair costs as much as opening all nodes
+// so covering solutions always rank first
+func calcCoveringCost(solution, hubs []int, links [][]int) float64 {
+	return float64(len(hubs) + len(cost_matrix)*calcUncovered(solution, hubs, links))
+}
+
+// printCoverage reports the largest route cost and the uncovered pairs of
+// a covering solution
+func printCoverage(solution, hubs []int, links [][]int) {
+	fmt.Printf("%-40s\tCover Radius[%f]\tMax Route Cost[%f]\tUncovered Pairs[%d]\n", "", coverRadius, calcCenterCost(solution, hubs, links), calcUncovered(solution, hubs, links))
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+// multi-period hub location: one flow matrix per period and a hub is open
+// from its opening period up to its closing period. Every opening costs
+// openingCost and every closing closingCost, in units of the normalized
+// cost of the first period, and the costs of period t are discounted by
+// (1 + discountRate)^t. Hubs only close when allowClosing is set.
+var periodFiles = ""
+var discountRate = 0.1
+var openingCost = 50.0
+var closingCost = 10.0
+var allowClosing = false
+
+var periodFlows = [][][]float64{}
+
+func multiPeriod() bool {
+	return len(periodFlows) > 0
+}
+
+// loadPeriods reads the flow matrix of every period
+func loadPeriods() error {
+	for t, location := range strings.Split(periodFiles, ",") {
+		matrix, err := readFlowScenario(location)
+		if err != nil {
+			return err
+		}
+		if t > 0 && len(matrix) != len(periodFlows[0]) {
+			return fmt.Errorf("period %d has %d nodes, expected %d", t+1, len(matrix), len(periodFlows[0]))
+		}
+		periodFlows = append(periodFlows, matrix)
+	}
+	return nil
+}
+
+func discount(period int) float64 {
+	return 1 / math.Pow(1+discountRate, float64(period))
+}
+
+// ScheduledHub is a hub open in the periods from Open up to but excluding
+// Close
+type ScheduledHub struct {
+	Node  int
+	Open  int
+	Close int
+}
+
+// Schedule is a multi-period solution, Periods holds the solution of every
+// period
+type Schedule struct {
+	Hubs           []ScheduledHub
+	Periods        []Candidate
+	Cost           float64
+	NormalizedCost float64
+	SwappedNode    int
+	ElapsedTime    time.Duration
+	Iteration      int
+	StopReason     string
+}
+
+type ScheduleVector []Schedule
+
+func (c ScheduleVector) Len() int {
+	return len(c)
+}
+
+func (c ScheduleVector) Less(i, j int) bool {
+	return c[i].Cost < c[j].Cost
+}
+
+func (c ScheduleVector) Swap(i, j int) {
+	c[j], c[i] = c[i], c[j]
+}
+
+func (s Schedule) hubsIn(period int) []int {
+	var hubs []int
+	for _, h := range s.Hubs {
+		if h.Open <= period && period < h.Close {
+			hubs = append(hubs, h.Node)
+		}
+	}
+	return hubs
+}
+
+func (s Schedule) isScheduled(node int) bool {
+	for _, h := range s.Hubs {
+		if h.Node == node {
+			return true
+		}
+	}
+	return false
+}
+
+// every period has between one and max_hubs hubs open, the forced hubs
+// are open in every period and forbidden nodes in none
+func (s Schedule) isValid(max_hubs int) bool {
+	for _, h := range s.Hubs {
+		if !canBeHub(h.Node) || (isForced(h.Node) && (h.Open > 0 || h.Close < len(periodFlows))) {
+			return false
+		}
+	}
+	for _, node := range forcedHubs {
+		if !s.isScheduled(node) {
+			return false
+		}
+	}
+	for t := range periodFlows {
+		if n := len(s.hubsIn(t)); n < 1 || n > max_hubs {
+			return false
+		}
+	}
+	return true
+}
+
+func (s Schedule) clone() Schedule {
+	c := Schedule{}
+	c.Hubs = make([]ScheduledHub, len(s.Hubs))
+	copy(c.Hubs, s.Hubs)
+	return c
+}
+
+// the solution of one period, the spokes are allocated to the open hubs as
+// in the initial solutions of the single period search
+func periodCandidate(hubs []int) Candidate {
+	candidate := Candidate{Hubs: hubs}
+	candidate.Solution = allocateNearest(hubs)
+	if allocation == "r" {
+		candidate.Links = allocateLinks(hubs)
+	}
+	if capacitated() {
+		candidate.Solution = allocateCapacitated(hubs)
+	}
+	if incompleteNetwork() {
+		candidate.Arcs = buildArcs(hubs)
+	}
+	return candidate
+}
+
+// calcCost evaluates every period with its own flows by the single period
+// evaluator and adds the discounted opening and closing costs
+func (s *Schedule) calcCost() {
+	saved_flow, saved_total := flow_matrix, total_flow
+	s.Periods = make([]Candidate, len(periodFlows))
+	s.Cost = 0
+	for t, matrix := range periodFlows {
+		flow_matrix = matrix
+		total_flow = calcTotalFlow(matrix)
+		s.Periods[t] = periodCandidate(s.hubsIn(t))
+		s.Periods[t].calcCost()
+		s.Cost += discount(t) * s.Periods[t].Cost
+	}
+	flow_matrix, total_flow = saved_flow, saved_total
+
+	for _, h := range s.Hubs {
+		s.Cost += discount(h.Open) * openingCost * total_flow
+		if h.Close < len(periodFlows) {
+			s.Cost += discount(h.Close) * closingCost * total_flow
+		}
+	}
+	s.NormalizedCost = s.Cost / total_flow
+}
+
+// the initial hubs are open in every period
+func initialSchedule(number_of_hubs int) Schedule {
+	s := Schedule{}
+	for _, hub := range get_initial_solution(cost_matrix, flow_matrix, alpha, number_of_hubs).Hubs {
+		s.Hubs = append(s.Hubs, ScheduledHub{Node: hub, Open: 0, Close: len(periodFlows)})
+	}
+	return s
+}
+
+// a random move of the schedule: relocate a hub to another node, open it
+// earlier or later, close it earlier or later, schedule a new hub or drop
+// one. Returns the neighbour and the moved node.
+func generateScheduleMove(current Schedule, max_hubs int) (Schedule, int) {
+	periods := len(periodFlows)
+	for attempt := 0; attempt < 100; attempt++ {
+		neighbor := current.clone()
+		k := rand.Intn(len(neighbor.Hubs))
+		node := neighbor.Hubs[k].Node
+
+		switch rand.Intn(5) {
+		case 0:
+			node = rand.Intn(len(cost_matrix))
+			if neighbor.isScheduled(node) {
+				continue
+			}
+			neighbor.Hubs[k].Node = node
+		case 1:
+			neighbor.Hubs[k].Open = rand.Intn(neighbor.Hubs[k].Close)
+		case 2:
+			if !allowClosing {
+				continue
+			}
+			neighbor.Hubs[k].Close = neighbor.Hubs[k].Open + 1 + rand.Intn(periods-neighbor.Hubs[k].Open)
+		case 3:
+			node = rand.Intn(len(cost_matrix))
+			if neighbor.isScheduled(node) {
+				continue
+			}
+			neighbor.Hubs = append(neighbor.Hubs, ScheduledHub{Node: node, Open: rand.Intn(periods), Close: periods})
+		case 4:
+			neighbor.Hubs = append(neighbor.Hubs[:k], neighbor.Hubs[k+1:]...)
+		}
+
+		if neighbor.isValid(max_hubs) && len(neighbor.Hubs) > 0 {
+			return neighbor, node
+		}
+	}
+	return current.clone(), current.Hubs[0].Node
+}
+
+// ScheduleSearch is the tabu search of the single period problem over hub
+// schedules, the moved node becomes tabu
+func ScheduleSearch(initial Schedule, tabuSize, maxCandidates, iterations, max_hubs int) (best Schedule) {
+	current := initial
+	best = current
+
+	tabuList := make([]int, tabuSize)
+
+	for i := 0; i < iterations; i++ {
+		if runTermination.Stop(i-best.Iteration, 10000, best.NormalizedCost) {
+			break
+		}
+
+		var candidates []Schedule
+		for j := 0; j < maxCandidates; j++ {
+			neighbor, node := generateScheduleMove(current, max_hubs)
+			neighbor.SwappedNode = node
+			neighbor.calcCost()
+			candidates = append(candidates, neighbor)
+		}
+		sort.Sort(ScheduleVector(candidates))
+
+		c := 0
+		for c < len(candidates)-1 && isInSlice(candidates[c].SwappedNode, tabuList) && (i-best.Iteration) <= aspiration && candidates[c].Cost >= best.Cost {
+			c++
+		}
+		current = candidates[c]
+		updateTabuList(current.SwappedNode, &tabuList, tabuSize)
+		if current.Cost < best.Cost {
+			best = current
+			best.Iteration = i
+		}
+	}
+	return best
+}
+
+// runSchedules solves the multi-period problem of the current
+// configuration and prints its row and the hubs and costs of every period
+func runSchedules(dataset string, tabuSize int) {
+	max_hubs := no_hubs
+	if max_hubs == 0 {
+		max_hubs = len(cost_matrix) - 1
+	}
+
+	var best []Schedule
+	primary_start_time := time.Now()
+	for k := 0; k < no_routines; k++ {
+		start := time.Now()
+		runTermination = newTermination(rootContext)
+		initial := initialSchedule(no_hubs)
+		initial.calcCost()
+		s := ScheduleSearch(initial, tabuSize, maxCandidates, iterations, max_hubs)
+		s.ElapsedTime = time.Since(start)
+		s.StopReason = runTermination.Done()
+		runTermination = nil
+		best = append(best, s)
+	}
+	sort.Sort(ScheduleVector(best))
+
+	average_tnc := 0.0
+	for _, s := range best {
+		average_tnc += s.NormalizedCost
+	}
+	average_tnc = average_tnc / float64(len(best))
+
+	var nodes []int
+	for _, h := range best[0].Hubs {
+		nodes = append(nodes, h.Node)
+	}
+	fmt.Printf("%-40s\t%-10d\t%-10f\t", dataset, len(nodes), alpha)
+	fmt.Printf("%-v\t%-20f\t%-20s\t%-20f\t%-20s\t%-20s\t%-20d\t%-20s\n", nodes, best[0].NormalizedCost, "-", average_tnc, best[0].ElapsedTime, time.Since(primary_start_time), best[0].Iteration, best[0].StopReason)
+	printSchedule(best[0])
+}
+
+// printSchedule reports the open hubs, the hubs opened and closed and the
+// normalized cost of every period
+func printSchedule(s Schedule) {
+	for t, c := range s.Periods {
+		hubs, opened, closed := []int{}, []int{}, []int{}
+		for _, hub := range c.Hubs {
+			hubs = append(hubs, hub+1)
+		}
+		for _, h := range s.Hubs {
+			if h.Open == t {
+				opened = append(opened, h.Node+1)
+			}
+			if h.Close == t {
+				closed = append(closed, h.Node+1)
+			}
+		}
+		fmt.Printf("%-40s\tPeriod[%d]\tHubs%v\tTNC[%f]\tOpened%v\tClosed%v\n", "", t+1, hubs, c.NormalizedCost, opened, closed)
+	}
+}
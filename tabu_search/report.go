@@ -0,0 +1,200 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// detailed report of the best solution of every configuration, -report
+// prints it below the result row and -report-json writes all of them.
+// Nodes are numbered from 1 as in the printed solutions.
+var reportText = false
+var reportJSON = ""
+var topRoutes = 10
+
+// HubReport is the flow a hub collects from and distributes to its spokes
+// and sends to and receives from the other hubs. The throughput is all
+// flow entering the hub, collected plus received.
+type HubReport struct {
+	Hub         int     `json:"hub"`
+	Spokes      []int   `json:"spokes"`
+	Collected   float64 `json:"collected"`
+	Distributed float64 `json:"distributed"`
+	TransferOut float64 `json:"transfer_out"`
+	TransferIn  float64 `json:"transfer_in"`
+	Throughput  float64 `json:"throughput"`
+}
+
+// RouteReport is the path origin -> first hub -> second hub -> destination
+// of an O-D pair and its cost
+type RouteReport struct {
+	Origin      int     `json:"origin"`
+	Destination int     `json:"destination"`
+	Path        []int   `json:"path"`
+	Flow        float64 `json:"flow"`
+	UnitCost    float64 `json:"unit_cost"`
+	Cost        float64 `json:"cost"`
+}
+
+// SolutionReport breaks the route costs of a solution down into
+// collection (spoke to hub), transfer (hub to hub) and distribution (hub
+// to spoke). Transfers are priced at alpha over the hub network or by the
+// scale model, every unit of a hub pair at the same share, Value is
+// the objective value of the search with fixed costs and penalties.
+type SolutionReport struct {
+	Dataset      string        `json:"dataset"`
+	Algorithm    string        `json:"algorithm,omitempty"`
+	Alpha        float64       `json:"alpha"`
+	Objective    string        `json:"objective"`
+	Value        float64       `json:"value"`
+	Hubs         []int         `json:"hubs"`
+	Solution     []int         `json:"solution,omitempty"`
+	Collection   float64       `json:"collection"`
+	Transfer     float64       `json:"transfer"`
+	Distribution float64       `json:"distribution"`
+	Total        float64       `json:"total"`
+	HubReports   []HubReport   `json:"hub_reports"`
+	HubFlows     [][]float64   `json:"hub_flows"`
+	TopRoutes    []RouteReport `json:"top_routes"`
+}
+
+var reports = []SolutionReport{}
+
+// the hubs k and m of the cheapest path from i to j and its three legs, the
+// transfer leg already discounted
+func routeLegs(i, j int, solution, hubs []int, links [][]int, dist [][]float64) (k, m int, legs [3]float64) {
+	price := func(k, m int) [3]float64 {
+		transfer := alpha * cost_matrix[k][m]
+		if dist != nil {
+			transfer = dist[k][m]
+		}
+		return [3]float64{cost_matrix[i][k], transfer, cost_matrix[m][j]}
+	}
+	var from, to []int
+	switch allocation {
+	case "multiple":
+		from, to = allowedHubs(i, hubs), allowedHubs(j, hubs)
+	case "r":
+		from, to = links[i], links[j]
+	default:
+		return solution[i], solution[j], price(solution[i], solution[j])
+	}
+	k, m = from[0], to[0]
+	legs = price(k, m)
+	for _, a := range from {
+		for _, b := range to {
+			l := price(a, b)
+			if l[0]+l[1]+l[2] < legs[0]+legs[1]+legs[2] {
+				k, m, legs = a, b, l
+			}
+		}
+	}
+	return k, m, legs
+}
+
+// buildReport reports a solution, value is its normalized objective value
+func buildReport(dataset, algorithm string, value float64, solution, hubs []int, links [][]int, arcs [][2]int) SolutionReport {
+	r := SolutionReport{Dataset: dataset, Algorithm: algorithm, Alpha: alpha, Objective: objective, Value: value}
+	index := map[int]int{}
+	for h, hub := range hubs {
+		index[hub] = h
+		r.Hubs = append(r.Hubs, hub+1)
+		r.HubReports = append(r.HubReports, HubReport{Hub: hub + 1, Spokes: []int{}})
+		r.HubFlows = append(r.HubFlows, make([]float64, len(hubs)))
+	}
+	// the allocation is only meaningful to single allocation, the spokes
+	// of a hub are otherwise the nodes that route some flow over it
+	if allocation == "single" {
+		for _, hub := range solution {
+			r.Solution = append(r.Solution, hub+1)
+		}
+	}
+	spoke := func(hub, node int) {
+		h := index[hub]
+		if hub != node && !isInSlice(node+1, r.HubReports[h].Spokes) {
+			r.HubReports[h].Spokes = append(r.HubReports[h].Spokes, node+1)
+		}
+	}
+
+	var dist [][]float64
+	if incompleteNetwork() {
+		dist = calcHubDistances(hubs, arcs)
+	}
+	if scaleModel != "constant" {
+		dist = calcScaledDistances(solution, hubs)
+	}
+	var routes []RouteReport
+	for i := range flow_matrix {
+		for j, w := range flow_matrix[i] {
+			if w == 0 {
+				continue
+			}
+			k, m, legs := routeLegs(i, j, solution, hubs, links, dist)
+			r.Collection += w * legs[0]
+			r.Transfer += w * legs[1]
+			r.Distribution += w * legs[2]
+			spoke(k, i)
+			spoke(m, j)
+			r.HubReports[index[k]].Collected += w
+			r.HubReports[index[m]].Distributed += w
+			if k != m {
+				r.HubReports[index[k]].TransferOut += w
+				r.HubReports[index[m]].TransferIn += w
+			}
+			r.HubFlows[index[k]][index[m]] += w
+			unit := legs[0] + legs[1] + legs[2]
+			routes = append(routes, RouteReport{Origin: i + 1, Destination: j + 1, Path: []int{i + 1, k + 1, m + 1, j + 1}, Flow: w, UnitCost: unit, Cost: w * unit})
+		}
+	}
+	r.Total = r.Collection + r.Transfer + r.Distribution
+	for h := range r.HubReports {
+		sort.Ints(r.HubReports[h].Spokes)
+		r.HubReports[h].Throughput = r.HubReports[h].Collected + r.HubReports[h].TransferIn
+	}
+
+	sort.SliceStable(routes, func(a, b int) bool { return routes[a].Cost > routes[b].Cost })
+	if len(routes) > topRoutes {
+		routes = routes[:topRoutes]
+	}
+	r.TopRoutes = routes
+	return r
+}
+
+// Print reports the solution below its result row, under single
+// allocation the Hubs and Solution lines can be used as a solution file
+func (r SolutionReport) Print() {
+	labels := func(nodes []int) string {
+		s := make([]string, len(nodes))
+		for k, node := range nodes {
+			s[k] = strconv.Itoa(node)
+		}
+		return strings.Join(s, " ")
+	}
+	fmt.Printf("%-40s\tHubs: %s\n", "", labels(r.Hubs))
+	if len(r.Solution) > 0 {
+		fmt.Printf("%-40s\tSolution: %s\n", "", labels(r.Solution))
+	}
+	fmt.Printf("%-40s\tCollection[%f]\tTransfer[%f]\tDistribution[%f]\tTotal[%f]\tNormalized[%f]\n", "", r.Collection, r.Transfer, r.Distribution, r.Total, r.Total/total_flow)
+	for h, hub := range r.HubReports {
+		fmt.Printf("%-40s\tHub[%d]\tSpokes[%s]\tCollected[%f]\tDistributed[%f]\tTransfer Out[%f]\tTransfer In[%f]\tThroughput[%f]\tTo Hubs:", "", hub.Hub, labels(hub.Spokes), hub.Collected, hub.Distributed, hub.TransferOut, hub.TransferIn, hub.Throughput)
+		for m, w := range r.HubFlows[h] {
+			fmt.Printf(" %d[%f]", r.Hubs[m], w)
+		}
+		fmt.Printf("\n")
+	}
+	for n, route := range r.TopRoutes {
+		fmt.Printf("%-40s\tRoute[%d]\tPath[%s]\tFlow[%f]\tUnit Cost[%f]\tCost[%f]\n", "", n+1, labels(route.Path), route.Flow, route.UnitCost, route.Cost)
+	}
+}
+
+func writeReportJSON(location string, reports []SolutionReport) error {
+	data, err := json.MarshalIndent(reports, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(location, data, 0644)
+}
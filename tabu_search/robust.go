@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// flow scenarios of the robust problem, comma separated flow matrix files
+// or a lower,upper pair of interval matrices. Intervals become the lower,
+// upper and midpoint scenarios plus intervalSamples scenarios with every
+// entry at one of its bounds. Without scenarios the flows are exact.
+var scenarioFiles = ""
+var intervalFiles = ""
+var intervalSamples = 10
+
+// robustCriterion is optimised over the scenarios: expected (mean) cost,
+// minmax (worst scenario cost) or regret (largest excess over the best
+// known cost of a scenario)
+var robustCriterion = "expected"
+
+var scenarios = [][][]float64{}
+var scenarioOptima = []float64{}
+
+func robust() bool {
+	return len(scenarios) > 0
+}
+
+// loadScenarios reads the scenario or interval files
+func loadScenarios() (err error) {
+	if scenarioFiles != "" && intervalFiles != "" {
+		return fmt.Errorf("give either flow scenarios or flow intervals")
+	}
+	if robustCriterion != "expected" && robustCriterion != "minmax" && robustCriterion != "regret" {
+		return fmt.Errorf("unknown robust criterion %q", robustCriterion)
+	}
+
+	if scenarioFiles != "" {
+		for _, location := range strings.Split(scenarioFiles, ",") {
+			matrix, err := readFlowScenario(location)
+			if err != nil {
+				return err
+			}
+			scenarios = append(scenarios, matrix)
+		}
+	}
+
+	if intervalFiles != "" {
+		locations := strings.Split(intervalFiles, ",")
+		if len(locations) != 2 {
+			return fmt.Errorf("flow intervals need a lower and an upper file")
+		}
+		lower, err := readFlowScenario(locations[0])
+		if err != nil {
+			return err
+		}
+		upper, err := readFlowScenario(locations[1])
+		if err != nil {
+			return err
+		}
+		scenarios, err = intervalScenarios(lower, upper)
+		if err != nil {
+			return err
+		}
+	}
+
+	for s, matrix := range scenarios {
+		if len(matrix) != len(scenarios[0]) {
+			return fmt.Errorf("scenario %d has %d nodes, expected %d", s+1, len(matrix), len(scenarios[0]))
+		}
+	}
+	return nil
+}
+
+// readFlowScenario reads a square flow matrix
+func readFlowScenario(location string) (matrix [][]float64, err error) {
+	f, err := os.Open(location)
+	if err != nil {
+		return matrix, err
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return matrix, err
+	}
+	for _, record := range records {
+		row := make([]float64, len(record))
+		for j, field := range record {
+			row[j], err = strconv.ParseFloat(strings.TrimSpace(field), 64)
+			if err != nil {
+				return matrix, err
+			}
+		}
+		if len(row) != len(records) {
+			return matrix, fmt.Errorf("%s is not a square matrix", location)
+		}
+		matrix = append(matrix, row)
+	}
+	return matrix, nil
+}
+
+// the scenarios of interval flows, the random vertex scenarios are drawn
+// from a fixed seed so every solver sees the same ones
+func intervalScenarios(lower, upper [][]float64) ([][][]float64, error) {
+	if len(lower) != len(upper) {
+		return nil, fmt.Errorf("the interval matrices differ in size")
+	}
+	n := len(lower)
+	middle := make([][]float64, n)
+	for i := range lower {
+		middle[i] = make([]float64, n)
+		for j := range lower[i] {
+			if lower[i][j] > upper[i][j] {
+				return nil, fmt.Errorf("flow interval %d,%d: lower bound above upper bound", i+1, j+1)
+			}
+			middle[i][j] = (lower[i][j] + upper[i][j]) / 2
+		}
+	}
+
+	result := [][][]float64{lower, upper, middle}
+	rng := rand.New(rand.NewSource(1))
+	for k := 0; k < intervalSamples; k++ {
+		vertex := make([][]float64, n)
+		for i := range vertex {
+			vertex[i] = make([]float64, n)
+			for j := range vertex[i] {
+				vertex[i][j] = lower[i][j]
+				if rng.Intn(2) == 0 {
+					vertex[i][j] = upper[i][j]
+				}
+			}
+		}
+		result = append(result, vertex)
+	}
+	return result, nil
+}
+
+// the mean of the scenarios replaces the flow matrix of the data set, the
+// costs are normalized by its total flow
+func meanScenario() [][]float64 {
+	n := len(scenarios[0])
+	mean := make([][]float64, n)
+	for i := range mean {
+		mean[i] = make([]float64, n)
+		for _, matrix := range scenarios {
+			for j := range matrix[i] {
+				mean[i][j] += matrix[i][j] / float64(len(scenarios))
+			}
+		}
+	}
+	return mean
+}
+
+// route cost of every O-D pair, they do not depend on the flows so they are
+// computed once for all scenarios
+func calcRouteCosts(solution, hubs []int, links [][]int, arcs [][2]int) [][]float64 {
+	var dist [][]float64
+	if incompleteNetwork() {
+		dist = calcHubDistances(hubs, arcs)
+	}
+	n := len(cost_matrix)
+	routes := make([][]float64, n)
+	for i := range routes {
+		routes[i] = make([]float64, n)
+		for j := range routes[i] {
+			if dist != nil {
+				routes[i][j] = cost_matrix[i][solution[i]] + dist[solution[i]][solution[j]] + cost_matrix[solution[j]][j]
+			} else {
+				routes[i][j] = calcRouteCost(i, j, solution, hubs, links)
+			}
+		}
+	}
+	return routes
+}
+
+// total cost of the solution under every scenario, fixed costs included
+func calcScenarioCosts(solution, hubs []int, links [][]int, arcs [][2]int) []float64 {
+	routes := calcRouteCosts(solution, hubs, links, arcs)
+	fixed := calcFixedCost(hubs)
+	costs := make([]float64, len(scenarios))
+	for s, matrix := range scenarios {
+		costs[s] = fixed
+		for i := range matrix {
+			for j, w := range matrix[i] {
+				costs[s] += w * routes[i][j]
+			}
+		}
+	}
+	return costs
+}
+
+// cost of the solution under the robust criterion
+func calcRobustCost(solution, hubs []int, links [][]int, arcs [][2]int) float64 {
+	costs := calcScenarioCosts(solution, hubs, links, arcs)
+	result := 0.0
+	switch robustCriterion {
+	case "minmax":
+		result = math.Inf(-1)
+		for _, c := range costs {
+			result = math.Max(result, c)
+		}
+	case "regret":
+		result = math.Inf(-1)
+		for s, c := range costs {
+			result = math.Max(result, c-scenarioOptima[s])
+		}
+	default:
+		for _, c := range costs {
+			result += c / float64(len(costs))
+		}
+	}
+	return result
+}
+
+// solveScenarios finds the best cost of every scenario on its own with
+// solve, the reference of the regret criterion. solve runs on the
+// scenario's flows with the robust problem switched off.
+func solveScenarios(solve func() float64) []float64 {
+	saved_flow, saved_total, saved_scenarios := flow_matrix, total_flow, scenarios
+	scenarios = nil
+	optima := make([]float64, len(saved_scenarios))
+	for s, matrix := range saved_scenarios {
+		flow_matrix = matrix
+		total_flow = calcTotalFlow(matrix)
+		optima[s] = solve()
+	}
+	flow_matrix, total_flow, scenarios = saved_flow, saved_total, saved_scenarios
+	return optima
+}
+
+// printScenarios reports the normalized cost of the solution under every
+// scenario, with the regret when the scenario optima are known
+func printScenarios(solution, hubs []int, links [][]int, arcs [][2]int) {
+	costs := calcScenarioCosts(solution, hubs, links, arcs)
+	fmt.Printf("%-40s\tScenario Costs:", "")
+	for s, c := range costs {
+		fmt.Printf(" %d[%f]", s+1, c/total_flow)
+	}
+	expected, worst := 0.0, math.Inf(-1)
+	for _, c := range costs {
+		expected += c / float64(len(costs))
+		worst = math.Max(worst, c)
+	}
+	fmt.Printf("\tExpected[%f]\tWorst[%f]", expected/total_flow, worst/total_flow)
+	if len(scenarioOptima) == len(costs) {
+		regret := math.Inf(-1)
+		for s, c := range costs {
+			regret = math.Max(regret, c-scenarioOptima[s])
+		}
+		fmt.Printf("\tMax Regret[%f]", regret/total_flow)
+	}
+	fmt.Printf("\n")
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// model of the inter-hub cost: constant discounts every hub to hub leg by
+// alpha, flowloc discounts the aggregated flow of a hub pair by a piecewise
+// linear concave function whose slope falls from 1 to alpha at the
+// breakpoints, threshold discounts by alpha only the hub pairs carrying at
+// least the threshold. Breakpoints and threshold are fractions of the total
+// flow.
+var scaleModel = "constant"
+var scaleBreakpoints = "0.02,0.05,0.1"
+var scaleThreshold = 0.05
+
+var breakpoints = []float64{}
+
+func parseScale() error {
+	switch scaleModel {
+	case "constant", "threshold":
+		return nil
+	case "flowloc":
+		breakpoints = breakpoints[:0]
+		previous := 0.0
+		for _, field := range strings.Split(scaleBreakpoints, ",") {
+			b, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+			if err != nil {
+				return err
+			}
+			if b <= previous {
+				return fmt.Errorf("the breakpoints must be positive and increasing")
+			}
+			breakpoints = append(breakpoints, b)
+			previous = b
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown scale model %q", scaleModel)
+}
+
+// cost factor of the flow of a hub pair, alpha * flow under the constant
+// model
+func calcScaledFlow(flow float64) float64 {
+	switch scaleModel {
+	case "threshold":
+		if flow >= scaleThreshold*total_flow {
+			return alpha * flow
+		}
+		return flow
+	case "flowloc":
+		scaled := 0.0
+		from := 0.0
+		for s, b := range breakpoints {
+			slope := 1 - (1-alpha)*float64(s)/float64(len(breakpoints))
+			to := b * total_flow
+			if flow <= to {
+				return scaled + slope*(flow-from)
+			}
+			scaled += slope * (to - from)
+			from = to
+		}
+		return scaled + alpha*(flow-from)
+	}
+	return alpha * flow
+}
+
+// total cost of a single allocation with the inter-hub cost of every hub
+// pair depending on the flow it carries
+func calcScaledCost(solution []int) float64 {
+	n := len(flow_matrix)
+	hub_flow := make([][]float64, n)
+	for k := range hub_flow {
+		hub_flow[k] = make([]float64, n)
+	}
+
+	total_cost := 0.0
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if flow_matrix[i][j] == 0 {
+				continue
+			}
+			total_cost += flow_matrix[i][j] * (cost_matrix[i][solution[i]] + cost_matrix[solution[j]][j])
+			hub_flow[solution[i]][solution[j]] += flow_matrix[i][j]
+		}
+	}
+	for k := 0; k < n; k++ {
+		for m := 0; m < n; m++ {
+			if k != m && hub_flow[k][m] > 0 {
+				total_cost += cost_matrix[k][m] * calcScaledFlow(hub_flow[k][m])
+			}
+		}
+	}
+	return total_cost
+}
+
+// unit cost of the transfer between every pair of hubs of a single
+// allocation, the scaled cost of the flow of the pair spread over its units
+func calcScaledDistances(solution, hubs []int) [][]float64 {
+	n := len(flow_matrix)
+	hub_flow := make([][]float64, n)
+	for k := range hub_flow {
+		hub_flow[k] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			hub_flow[solution[i]][solution[j]] += flow_matrix[i][j]
+		}
+	}
+	dist := make([][]float64, n)
+	for _, k := range hubs {
+		dist[k] = make([]float64, n)
+		for _, m := range hubs {
+			if k != m && hub_flow[k][m] > 0 {
+				dist[k][m] = cost_matrix[k][m] * calcScaledFlow(hub_flow[k][m]) / hub_flow[k][m]
+			}
+		}
+	}
+	return dist
+}
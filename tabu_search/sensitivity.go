@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sensitivity command configuration, the instance and the alpha and p grid
+var sensCostFile = "Cost_matrix10.csv"
+var sensFlowFile = "Flow_matrix10.csv"
+var sensNodes = 10
+var sensAlphaMin = 0.0
+var sensAlphaMax = 1.0
+var sensAlphaStep = 0.05
+var sensHubsMin = 2
+var sensHubsMax = 5
+var sensRestarts = 2
+var sensCSV = ""
+
+// SensitivityStep is the best solution found for one p and alpha, Changed
+// marks a breakpoint where the hubs differ from the previous alpha
+type SensitivityStep struct {
+	NoHubs  int
+	Alpha   float64
+	Hubs    []int
+	TNC     float64
+	Changed bool
+}
+
+// the alphas from min to max by step, the last one is max
+func sensitivityAlphas() []float64 {
+	steps := int(math.Round((sensAlphaMax - sensAlphaMin) / sensAlphaStep))
+	alphas := []float64{}
+	for k := 0; k <= steps; k++ {
+		a := math.Min(sensAlphaMin+float64(k)*sensAlphaStep, sensAlphaMax)
+		alphas = append(alphas, a)
+	}
+	if alphas[len(alphas)-1] < sensAlphaMax {
+		alphas = append(alphas, sensAlphaMax)
+	}
+	return alphas
+}
+
+// sweepAlpha solves p hubs for every alpha, each alpha starts one search
+// from the best solution of the previous alpha next to the random restarts.
+// Every alpha is then priced again with the solutions of all alphas, with
+// their allocation and with the spokes at their nearest hub, so a good
+// solution found late replaces a worse one found early and the hubs only
+// change where another set is cheaper.
+func sweepAlpha(p int, alphas []float64) []SensitivityStep {
+	no_hubs = p
+	tabuSize := sensNodes / tabuSizeDivider
+	maxCandidates = sensNodes * maxCandidatesMultiplier
+
+	solutions := make([]Candidate, len(alphas))
+	for k, a := range alphas {
+		alpha = a
+		var best []Candidate
+		if k > 0 {
+			c := Candidate{Hubs: append([]int{}, solutions[k-1].Hubs...), Solution: append([]int{}, solutions[k-1].Solution...)}
+			c.calcCost()
+			best = append(best, TabuSearch(c, cost_matrix, flow_matrix, tabuSize, maxCandidates, iterations, alpha))
+		}
+		for r := 0; r < sensRestarts || len(best) == 0; r++ {
+			c := get_initial_solution(cost_matrix, flow_matrix, alpha, no_hubs)
+			c.calcCost()
+			best = append(best, TabuSearch(c, cost_matrix, flow_matrix, tabuSize, maxCandidates, iterations, alpha))
+		}
+		sort.Sort(CandidateVector(best))
+		solutions[k] = best[0]
+	}
+	pool := append([]Candidate{}, solutions...)
+	for k, a := range alphas {
+		alpha = a
+		for _, other := range pool {
+			c := Candidate{Hubs: other.Hubs, Solution: other.Solution}
+			c.calcCost()
+			c = nearestCandidate(c)
+			if c.Cost < solutions[k].Cost {
+				solutions[k] = c
+			}
+		}
+	}
+
+	var steps []SensitivityStep
+	for k, c := range solutions {
+		hubs := append([]int{}, c.Hubs...)
+		sort.Ints(hubs)
+		step := SensitivityStep{NoHubs: p, Alpha: alphas[k], Hubs: hubs, TNC: c.NormalizedCost}
+		if k > 0 {
+			for h, hub := range hubs {
+				if hub != steps[k-1].Hubs[h] {
+					step.Changed = true
+				}
+			}
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+func hubLabels(hubs []int) string {
+	labels := make([]string, len(hubs))
+	for k, hub := range hubs {
+		labels[k] = strconv.Itoa(hub + 1)
+	}
+	return strings.Join(labels, " ")
+}
+
+func writeSensitivityCSV(location string, steps []SensitivityStep) error {
+	f, err := os.Create(location)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"no_hubs", "alpha", "tnc", "hub_locations", "breakpoint"})
+	for _, s := range steps {
+		w.Write([]string{
+			strconv.Itoa(s.NoHubs),
+			fmt.Sprintf("%f", s.Alpha),
+			fmt.Sprintf("%f", s.TNC),
+			hubLabels(s.Hubs),
+			strconv.FormatBool(s.Changed),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// sensitivity is the sensitivity command, it sweeps alpha for every number
+// of hubs and reports the alphas where the best hubs change
+func sensitivity(args []string) error {
+	fs := flag.NewFlagSet("sensitivity", flag.ExitOnError)
+	fs.StringVar(&sensCostFile, "cost", sensCostFile, "cost matrix CSV")
+	fs.StringVar(&sensFlowFile, "flow", sensFlowFile, "flow matrix CSV")
+	fs.IntVar(&sensNodes, "nodes", sensNodes, "number of nodes of the instance")
+	fs.Float64Var(&sensAlphaMin, "alpha-min", sensAlphaMin, "first alpha of the sweep")
+	fs.Float64Var(&sensAlphaMax, "alpha-max", sensAlphaMax, "last alpha of the sweep")
+	fs.Float64Var(&sensAlphaStep, "alpha-step", sensAlphaStep, "step between two alphas of the sweep")
+	fs.IntVar(&sensHubsMin, "hubs-min", sensHubsMin, "smallest number of hubs")
+	fs.IntVar(&sensHubsMax, "hubs-max", sensHubsMax, "largest number of hubs")
+	fs.IntVar(&sensRestarts, "restarts", sensRestarts, "random restarts per alpha next to the search from the previous best")
+	fs.IntVar(&iterations, "iterations", 1000, "iterations of every tabu search")
+	fs.StringVar(&sensCSV, "csv", sensCSV, "CSV file of the TNC per number of hubs and alpha")
+	fs.StringVar(&constraintsFile, "hub-constraints", constraintsFile, "CSV of forced,NODE, forbidden,NODE and forbidden_allocation,SPOKE,HUB rows, nodes numbered from 1")
+	fs.StringVar(&forcedOption, "forced-hubs", forcedOption, "comma separated nodes, numbered from 1, that are always hubs")
+	fs.StringVar(&forbiddenOption, "forbidden-hubs", forbiddenOption, "comma separated nodes, numbered from 1, that are never hubs")
+	fs.StringVar(&forbiddenAllocationOption, "forbidden-allocations", forbiddenAllocationOption, "comma separated SPOKE:HUB pairs, numbered from 1, of allocations that are not allowed")
+	fs.Parse(args)
+
+	if sensAlphaMin < 0 || sensAlphaMax > 1 || sensAlphaMin > sensAlphaMax {
+		return fmt.Errorf("the alphas must satisfy 0 <= alpha-min <= alpha-max <= 1")
+	}
+	if sensAlphaStep <= 0 {
+		return fmt.Errorf("the alpha step must be positive, got %f", sensAlphaStep)
+	}
+	if sensHubsMin < 1 || sensHubsMin > sensHubsMax || sensHubsMax >= sensNodes {
+		return fmt.Errorf("the hubs must satisfy 1 <= hubs-min <= hubs-max < nodes")
+	}
+	if sensRestarts < 0 {
+		return fmt.Errorf("the restarts must be 0 or more, got %d", sensRestarts)
+	}
+
+	var err error
+	cost_matrix, err = read_matrix(sensCostFile, sensNodes)
+	if err != nil {
+		return err
+	}
+	flow_matrix, err = read_matrix(sensFlowFile, sensNodes)
+	if err != nil {
+		return err
+	}
+	total_flow = calcTotalFlow(flow_matrix)
+
+	err = loadHubConstraints()
+	if err != nil {
+		return err
+	}
+	for p := sensHubsMin; p <= sensHubsMax; p++ {
+		err = checkHubConstraints(sensNodes, p)
+		if err != nil {
+			return err
+		}
+	}
+
+	alphas := sensitivityAlphas()
+	fmt.Printf("Sensitivity: Cost[%s]\tFlow[%s]\tNodes[%d]\tAlpha[%0.3f-%0.3f]\tStep[%0.3f]\tHubs[%d-%d]\tRestarts[%d]\tIterations[%d]\n", sensCostFile, sensFlowFile, sensNodes, sensAlphaMin, sensAlphaMax, sensAlphaStep, sensHubsMin, sensHubsMax, sensRestarts, iterations)
+	if hubConstraints() {
+		printHubConstraints()
+	}
+	fmt.Printf("%-10s\t%-10s\t%-20s\t%-20s\t%-10s\n", "No Hubs", "Alpha", "Hub Locations", "TNC", "Breakpoint")
+	var all []SensitivityStep
+	for p := sensHubsMin; p <= sensHubsMax; p++ {
+		steps := sweepAlpha(p, alphas)
+		for _, s := range steps {
+			breakpoint := ""
+			if s.Changed {
+				breakpoint = "*"
+			}
+			fmt.Printf("%-10d\t%-10f\t%-20s\t%-20f\t%-10s\n", s.NoHubs, s.Alpha, hubLabels(s.Hubs), s.TNC, breakpoint)
+		}
+		for k, s := range steps {
+			if s.Changed {
+				fmt.Printf("Breakpoint: No Hubs[%d]\tAlpha[%f-%f]\tFrom[%s]\tTo[%s]\n", p, steps[k-1].Alpha, s.Alpha, hubLabels(steps[k-1].Hubs), hubLabels(s.Hubs))
+			}
+		}
+		all = append(all, steps...)
+	}
+
+	if sensCSV != "" {
+		return writeSensitivityCSV(sensCSV, all)
+	}
+	return nil
+}
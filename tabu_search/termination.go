@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// termination policy of the runs, a run stops on the first of its own
+// iteration or generation limit, the wall clock limit, the budget of cost
+// evaluations, the target cost, the stall limit and the cancellation of
+// its context. Zero leaves a limit out, a zero stall limit keeps the stall
+// limit of every algorithm.
+var timeLimit time.Duration
+var maxEvaluations int64
+var targetCost = 0.0
+var stallLimit = 0
+
+// the context the runs derive from, cancelled on an interrupt
+var rootContext = context.Background()
+
+// the termination of the running search, nil outside of the runs
+var runTermination *Termination
+
+type Termination struct {
+	ctx         context.Context
+	cancel      context.CancelFunc
+	evaluations int64
+	mu          sync.Mutex
+	Reason      string
+}
+
+func newTermination(parent context.Context) *Termination {
+	t := &Termination{}
+	if timeLimit > 0 {
+		t.ctx, t.cancel = context.WithTimeout(parent, timeLimit)
+	} else {
+		t.ctx, t.cancel = context.WithCancel(parent)
+	}
+	return t
+}
+
+func checkTermination() error {
+	if timeLimit < 0 || maxEvaluations < 0 || targetCost < 0 || stallLimit < 0 {
+		return fmt.Errorf("the time limit, evaluations, target cost and stall limit must be 0 or more")
+	}
+	return nil
+}
+
+func terminationLimits() bool {
+	return timeLimit > 0 || maxEvaluations > 0 || targetCost > 0 || stallLimit > 0
+}
+
+// printTermination reports the limits in the configuration header
+func printTermination() {
+	fmt.Printf("Termination: Time Limit[%s]\tMax Evaluations[%d]\tTarget Cost[%f]\tStall Limit[%d]\n", timeLimit, maxEvaluations, targetCost, stallLimit)
+}
+
+// Evaluated counts a cost evaluation, the islands count concurrently
+func (t *Termination) Evaluated() {
+	if t == nil {
+		return
+	}
+	atomic.AddInt64(&t.evaluations, 1)
+}
+
+func (t *Termination) Evaluations() int64 {
+	if t == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&t.evaluations)
+}
+
+// Stop tells whether the run stops after stalled iterations without
+// improvement of the normalized best cost. stall is the stall limit of the
+// algorithm, negative for none, and replaced by stallLimit when set. A nil
+// termination only stops on the stall limit, a stopped one stays stopped.
+func (t *Termination) Stop(stalled, stall int, best float64) bool {
+	if stallLimit > 0 {
+		stall = stallLimit
+	}
+	if t == nil {
+		return stall >= 0 && stalled > stall
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.Reason != "" {
+		return true
+	}
+	switch {
+	case t.ctx.Err() == context.DeadlineExceeded:
+		t.Reason = "time"
+	case t.ctx.Err() != nil:
+		t.Reason = "cancelled"
+	case maxEvaluations > 0 && t.Evaluations() >= maxEvaluations:
+		t.Reason = "evaluations"
+	case targetCost > 0 && best <= targetCost:
+		t.Reason = "target"
+	case stall >= 0 && stalled > stall:
+		t.Reason = "stall"
+	}
+	return t.Reason != ""
+}
+
+// Done releases the context of the run and returns why it stopped, a run
+// that was not stopped ran out of iterations
+func (t *Termination) Done() string {
+	t.cancel()
+	if t.Reason == "" {
+		t.Reason = "iterations"
+	}
+	return t.Reason
+}
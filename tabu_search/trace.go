@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// convergence traces, one CSV or JSONL file per run in traceDir with a
+// point per iteration or generation. The islands of the island model add
+// their points concurrently, a generation then has a point per island with
+// the best cost of that island.
+var traceDir = ""
+var traceFormat = "csv"
+
+// the trace of the running search, nil when no trace is written
+var runTrace *Trace
+
+// TracePoint is the state of a run after an iteration, the current cost is
+// that of the current solution of a tabu search and of the best organism
+// of the generation, diversity the share of distinct hub sets in the
+// population. Elapsed is in seconds.
+type TracePoint struct {
+	Iteration int     `json:"iteration"`
+	Elapsed   float64 `json:"elapsed"`
+	Current   float64 `json:"current"`
+	Best      float64 `json:"best"`
+	TabuSize  int     `json:"tabu_size"`
+	Diversity float64 `json:"diversity"`
+}
+
+type Trace struct {
+	mu     sync.Mutex
+	start  time.Time
+	Points []TracePoint
+}
+
+func newTrace() *Trace {
+	return &Trace{start: time.Now()}
+}
+
+// Add records an iteration, a nil trace records nothing
+func (t *Trace) Add(iteration int, current, best float64, tabuSize int, diversity float64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Points = append(t.Points, TracePoint{
+		Iteration: iteration,
+		Elapsed:   time.Since(t.start).Seconds(),
+		Current:   current,
+		Best:      best,
+		TabuSize:  tabuSize,
+		Diversity: diversity,
+	})
+}
+
+// checkTrace validates the trace format and creates the trace directory
+func checkTrace() error {
+	if traceDir == "" {
+		return nil
+	}
+	if traceFormat != "csv" && traceFormat != "jsonl" {
+		return fmt.Errorf("unknown trace format %s, expected csv or jsonl", traceFormat)
+	}
+	return os.MkdirAll(traceDir, 0755)
+}
+
+// the trace file of a run of an algorithm on a data set with p hubs at the
+// current alpha
+func traceFile(dataset, algorithm string, p, run int) string {
+	name := strings.TrimSuffix(filepath.Base(dataset), filepath.Ext(dataset))
+	return filepath.Join(traceDir, fmt.Sprintf("%s_%s_%d_%f_%d.%s", name, algorithm, p, alpha, run, traceFormat))
+}
+
+func writeTrace(location string, t *Trace) error {
+	f, err := os.Create(location)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if traceFormat == "jsonl" {
+		encoder := json.NewEncoder(f)
+		for _, p := range t.Points {
+			err = encoder.Encode(p)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"iteration", "elapsed", "current", "best", "tabu_size", "diversity"})
+	for _, p := range t.Points {
+		w.Write([]string{
+			strconv.Itoa(p.Iteration),
+			fmt.Sprintf("%f", p.Elapsed),
+			fmt.Sprintf("%f", p.Current),
+			fmt.Sprintf("%f", p.Best),
+			strconv.Itoa(p.TabuSize),
+			fmt.Sprintf("%f", p.Diversity),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
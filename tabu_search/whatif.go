@@ -0,0 +1,271 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// whatif command configuration, the instance, the solution file and the
+// hub failure probabilities, one per node as the last column of a CSV or
+// the same for every hub
+var whatifCostFile = "Cost_matrix10.csv"
+var whatifFlowFile = "Flow_matrix10.csv"
+var whatifNodes = 10
+var whatifSolutionFile = ""
+var whatifReoptimise = false
+var whatifRestarts = 2
+var whatifReliable = false
+var failureFile = ""
+var failureProbability = 0.0
+
+var failureProbabilities = []float64{}
+
+// the tabu search minimises the expected cost under hub failures while
+// the reliable hubs are searched
+var reliableSearch = false
+
+// failHub closes the failed hub, the hub and its spokes are allocated to
+// the nearest remaining hub they may be allocated to, the other spokes
+// keep their hub
+func failHub(hubs, solution []int, failed int) ([]int, []int) {
+	remaining := []int{}
+	for _, hub := range hubs {
+		if hub != failed {
+			remaining = append(remaining, hub)
+		}
+	}
+	reallocated := append([]int{}, solution...)
+	for i, hub := range solution {
+		if hub != failed {
+			continue
+		}
+		allowed := allowedHubs(i, remaining)
+		target := allowed[0]
+		for _, h := range allowed {
+			if cost_matrix[i][h] < cost_matrix[i][target] {
+				target = h
+			}
+		}
+		reallocated[i] = target
+	}
+	return remaining, reallocated
+}
+
+// the probabilities that no hub fails and that only each hub fails, hubs
+// fail independently and the scenarios of several failed hubs are left out
+// so the probabilities are scaled to sum to one
+func failureScenarios(hubs []int) (none float64, single []float64) {
+	none = 1.0
+	for _, hub := range hubs {
+		none *= 1 - failureProbabilities[hub]
+	}
+	single = make([]float64, len(hubs))
+	sum := none
+	for k, hub := range hubs {
+		single[k] = failureProbabilities[hub]
+		for _, other := range hubs {
+			if other != hub {
+				single[k] *= 1 - failureProbabilities[other]
+			}
+		}
+		sum += single[k]
+	}
+	for k := range single {
+		single[k] /= sum
+	}
+	return none / sum, single
+}
+
+// calcExpectedCost is the cost of a single allocation solution over the
+// scenarios of no failed hub and of every single failed hub, the spokes of
+// a failed hub are allocated to their nearest remaining hub
+func calcExpectedCost(solution, hubs []int) float64 {
+	if len(hubs) < 2 {
+		return calcTotalCost(cost_matrix, flow_matrix, alpha, solution)
+	}
+	none, single := failureScenarios(hubs)
+	expected := none * calcTotalCost(cost_matrix, flow_matrix, alpha, solution)
+	for k, hub := range hubs {
+		_, reallocated := failHub(hubs, solution, hub)
+		expected += single[k] * calcTotalCost(cost_matrix, flow_matrix, alpha, reallocated)
+	}
+	return expected
+}
+
+// reoptimise runs a tabu search on the hubs left after the failure, the
+// failed hub may not open again
+func reoptimise(c Candidate, failed int) Candidate {
+	forced, forbidden := forcedHubs, forbiddenHubs
+	forcedHubs = []int{}
+	for _, node := range forced {
+		if node != failed {
+			forcedHubs = append(forcedHubs, node)
+		}
+	}
+	forbiddenHubs = append(append([]int{}, forbidden...), failed)
+	defer func() {
+		forcedHubs, forbiddenHubs = forced, forbidden
+	}()
+
+	no_hubs = len(c.Hubs)
+	best := []Candidate{nearestCandidate(c)}
+	best = append(best, nearestCandidate(TabuSearch(c, cost_matrix, flow_matrix, whatifNodes/tabuSizeDivider, maxCandidates, iterations, alpha)))
+	sort.Sort(CandidateVector(best))
+	return best[0]
+}
+
+// printFailures reports the cost of the solution when each of its hubs
+// fails and, reoptimised, the best hubs without it
+func printFailures(hubs, solution []int) {
+	base := calcTotalCost(cost_matrix, flow_matrix, alpha, solution) / total_flow
+	fmt.Printf("%-10s\t%-12s\t%-20s\t%-20s\t%-20s\t%-12s", "Failed Hub", "Probability", "Hub Locations", "TNC", "Increase", "Increase %")
+	if whatifReoptimise {
+		fmt.Printf("\t%-20s\t%-20s\t%-20s", "Reoptimised Hubs", "Reoptimised TNC", "Recovered")
+	}
+	fmt.Printf("\n")
+	worst, worstHub := 0.0, -1
+	for _, hub := range hubs {
+		c := Candidate{}
+		c.Hubs, c.Solution = failHub(hubs, solution, hub)
+		c.calcCost()
+		tnc := calcTotalCost(cost_matrix, flow_matrix, alpha, c.Solution) / total_flow
+		if tnc-base > worst {
+			worst, worstHub = tnc-base, hub
+		}
+		fmt.Printf("%-10d\t%-12f\t%-20s\t%-20f\t%-20f\t%-12s", hub+1, failureProbabilities[hub], hubLabels(c.Hubs), tnc, tnc-base, fmt.Sprintf("%.2f%%", (tnc-base)/base*100))
+		if whatifReoptimise {
+			r := reoptimise(c, hub)
+			reoptimised := calcTotalCost(cost_matrix, flow_matrix, alpha, r.Solution) / total_flow
+			fmt.Printf("\t%-20s\t%-20f\t%-20f", hubLabels(r.Hubs), reoptimised, tnc-reoptimised)
+		}
+		fmt.Printf("\n")
+	}
+	if worstHub >= 0 {
+		fmt.Printf("Worst Failure: Hub[%d]\tIncrease[%f]\n", worstHub+1, worst)
+	}
+	if len(hubs) > 1 {
+		fmt.Printf("Expected: TNC[%f]\tExpected TNC[%f]\n", base, calcExpectedCost(solution, hubs)/total_flow)
+	}
+}
+
+// searchReliable looks for the hubs of the least expected cost under the
+// failure probabilities, from the solution and from random restarts
+func searchReliable(hubs, solution []int) Candidate {
+	reliableSearch = true
+	defer func() { reliableSearch = false }()
+
+	no_hubs = len(hubs)
+	start := Candidate{Hubs: append([]int{}, hubs...), Solution: append([]int{}, solution...)}
+	start.calcCost()
+	best := []Candidate{start, nearestCandidate(TabuSearch(start, cost_matrix, flow_matrix, whatifNodes/tabuSizeDivider, maxCandidates, iterations, alpha))}
+	for r := 0; r < whatifRestarts; r++ {
+		c := get_initial_solution(cost_matrix, flow_matrix, alpha, no_hubs)
+		c.calcCost()
+		best = append(best, nearestCandidate(TabuSearch(c, cost_matrix, flow_matrix, whatifNodes/tabuSizeDivider, maxCandidates, iterations, alpha)))
+	}
+	sort.Sort(CandidateVector(best))
+	return best[0]
+}
+
+// whatif is the whatif command, it fails every hub of a single allocation
+// solution in turn and optionally searches hubs that are reliable under
+// the failure probabilities
+func whatif(args []string) error {
+	fs := flag.NewFlagSet("whatif", flag.ExitOnError)
+	fs.StringVar(&whatifCostFile, "cost", whatifCostFile, "cost matrix CSV")
+	fs.StringVar(&whatifFlowFile, "flow", whatifFlowFile, "flow matrix CSV")
+	fs.IntVar(&whatifNodes, "nodes", whatifNodes, "number of nodes of the instance")
+	fs.Float64Var(&alpha, "alpha", alpha, "discount factor of the hub to hub transport")
+	fs.StringVar(&whatifSolutionFile, "solution", whatifSolutionFile, "solution file with Hubs: and Solution: lines, nodes numbered from 1")
+	fs.BoolVar(&whatifReoptimise, "reoptimise", whatifReoptimise, "run a tabu search on the hubs left after every failure")
+	fs.IntVar(&iterations, "iterations", 100, "iterations of every tabu search")
+	fs.StringVar(&failureFile, "failure-probabilities", failureFile, "CSV of the failure probability of every node (last column)")
+	fs.Float64Var(&failureProbability, "failure-probability", failureProbability, "failure probability of every hub, without a failure probabilities file")
+	fs.BoolVar(&whatifReliable, "reliable", whatifReliable, "search the hubs of the least expected cost under the failure probabilities")
+	fs.IntVar(&whatifRestarts, "restarts", whatifRestarts, "random restarts of the reliable hub search")
+	fs.StringVar(&constraintsFile, "hub-constraints", constraintsFile, "CSV of forced,NODE, forbidden,NODE and forbidden_allocation,SPOKE,HUB rows, nodes numbered from 1")
+	fs.StringVar(&forcedOption, "forced-hubs", forcedOption, "comma separated nodes, numbered from 1, that are always hubs")
+	fs.StringVar(&forbiddenOption, "forbidden-hubs", forbiddenOption, "comma separated nodes, numbered from 1, that are never hubs")
+	fs.StringVar(&forbiddenAllocationOption, "forbidden-allocations", forbiddenAllocationOption, "comma separated SPOKE:HUB pairs, numbered from 1, of allocations that are not allowed")
+	fs.Parse(args)
+
+	if whatifSolutionFile == "" {
+		return fmt.Errorf("no solution file given")
+	}
+	if whatifRestarts < 0 {
+		return fmt.Errorf("the restarts must be 0 or more, got %d", whatifRestarts)
+	}
+
+	var err error
+	cost_matrix, err = read_matrix(whatifCostFile, whatifNodes)
+	if err != nil {
+		return err
+	}
+	flow_matrix, err = read_matrix(whatifFlowFile, whatifNodes)
+	if err != nil {
+		return err
+	}
+	total_flow = calcTotalFlow(flow_matrix)
+	maxCandidates = whatifNodes * maxCandidatesMultiplier
+
+	if failureFile != "" {
+		failureProbabilities, err = readFixedCosts(failureFile)
+		if err != nil {
+			return err
+		}
+		if len(failureProbabilities) != whatifNodes {
+			return fmt.Errorf("the failure probabilities have %d nodes, the instance %d", len(failureProbabilities), whatifNodes)
+		}
+	} else {
+		failureProbabilities = make([]float64, whatifNodes)
+		for i := range failureProbabilities {
+			failureProbabilities[i] = failureProbability
+		}
+	}
+	for i, q := range failureProbabilities {
+		if q < 0 || q >= 1 || math.IsNaN(q) {
+			return fmt.Errorf("the failure probability of node %d must be in [0, 1), got %f", i+1, q)
+		}
+	}
+
+	err = loadHubConstraints()
+	if err != nil {
+		return err
+	}
+	err = checkHubConstraints(whatifNodes, 0)
+	if err != nil {
+		return err
+	}
+
+	hubs, solution, err := readSolutionFile(whatifSolutionFile)
+	if err != nil {
+		return err
+	}
+	violations := checkSolution(hubs, solution, 0)
+	if len(violations) > 0 {
+		return fmt.Errorf("the solution is infeasible, %s", violations[0])
+	}
+	if len(hubs) < 2 {
+		return fmt.Errorf("a solution of %d hub has no hub left after a failure", len(hubs))
+	}
+	if whatifReliable && failureFile == "" && failureProbability == 0 {
+		return fmt.Errorf("the reliable hubs need failure probabilities")
+	}
+
+	fmt.Printf("What If: Cost[%s]\tFlow[%s]\tNodes[%d]\tAlpha[%f]\tSolution[%s]\tReoptimise[%t]\tIterations[%d]\n", whatifCostFile, whatifFlowFile, whatifNodes, alpha, whatifSolutionFile, whatifReoptimise, iterations)
+	if hubConstraints() {
+		printHubConstraints()
+	}
+	fmt.Printf("Hubs[%s]\tTNC[%f]\n", hubLabels(hubs), calcTotalCost(cost_matrix, flow_matrix, alpha, solution)/total_flow)
+	printFailures(hubs, solution)
+
+	if whatifReliable {
+		r := searchReliable(hubs, solution)
+		fmt.Printf("Reliable: Hubs[%s]\tTNC[%f]\tExpected TNC[%f]\tRestarts[%d]\n", hubLabels(r.Hubs), calcTotalCost(cost_matrix, flow_matrix, alpha, r.Solution)/total_flow, r.Cost/total_flow, whatifRestarts)
+		fmt.Printf("Solution: %s\n", hubLabels(r.Solution))
+		printFailures(r.Hubs, r.Solution)
+	}
+	return nil
+}